@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
@@ -10,8 +11,14 @@ import (
 // First we create a MAP (to store [KEY][VALUE] -> Key is the name of the database and *gorm.DB is a reference from an already open database conection)
 var DBs map[string]*gorm.DB = make(map[string]*gorm.DB)
 
+// dbsMutex guards DBs, households opened at runtime add connections while requests read them
+var dbsMutex sync.RWMutex
+
 func ConnectDB(alias string, path string) (*gorm.DB, error) {
 
+	dbsMutex.Lock()
+	defer dbsMutex.Unlock()
+
 	// Singleton - Check if DB connection already exists
 	if db, exists := DBs[alias]; exists {
 		return db, nil
@@ -26,3 +33,11 @@ func ConnectDB(alias string, path string) (*gorm.DB, error) {
 	DBs[alias] = db
 	return db, nil
 }
+
+// GetDB returns an already open connection by its alias
+func GetDB(alias string) (*gorm.DB, bool) {
+	dbsMutex.RLock()
+	defer dbsMutex.RUnlock()
+	db, ok := DBs[alias]
+	return db, ok
+}
@@ -1,38 +1,309 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
-func LoadEnv() {
-	err := godotenv.Load()
+// Sources of a setting value, from the lowest to the highest priority
+const (
+	SourceDefault  = "default"
+	SourceFile     = "file"
+	SourceEnv      = "env"
+	SourceDatabase = "database"
+	SourceTenant   = "household" // stored for one household, see SetTenantValues
+)
+
+// SettingValue is a setting with its effective value and where it comes from
+type SettingValue struct {
+	Setting
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+var (
+	valuesMutex    sync.RWMutex
+	fileValues     = map[string]string{}
+	databaseValues = map[string]string{}
+	tenantValues   = map[uint]map[string]string{}
+)
+
+/*
+Load reads the configuration layers on disk and validates the result:
+- the .env file, optional, loaded into the environment (the variables already set win)
+- the JSON file of CONFIG_FILE ("config.json" by default), optional, e.g. {"PORT": 8080, "CARD_UPLOADS_PATH": "/data/uploads"}
+Each setting takes the first value of: database (see SetDatabaseValues), env, file, default.
+The Household settings are looked up first in the values stored for the household (see SetTenantValues).
+*/
+func Load() error {
+
+	if err := godotenv.Load(); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error loading .env: %w", err)
+		}
+		log.Println("no .env file, reading the configuration from the environment")
+	}
+
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		path = "config.json"
+	}
+	values, err := readConfigFile(path)
 	if err != nil {
-		log.Fatal("Error loading .env")
+		return err
+	}
+
+	valuesMutex.Lock()
+	fileValues = values
+	valuesMutex.Unlock()
+
+	return Validate()
+}
+
+// Validate checks the effective value of every setting, returning all the problems found
+func Validate() error {
+	var problems []error
+	for _, setting := range Settings {
+		value, _ := lookup(setting.Key)
+		if err := setting.ValidateValue(value); err != nil {
+			problems = append(problems, fmt.Errorf("%s %w", setting.Key, err))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+/*
+SetDatabaseValues replaces the layer stored in the database, the highest priority one.
+Only runtime settings are accepted, they apply from the next read.
+*/
+func SetDatabaseValues(values map[string]string) error {
+	for key, value := range values {
+		if err := ValidateRuntimeValue(key, value); err != nil {
+			return err
+		}
+	}
+
+	copied := make(map[string]string, len(values))
+	for key, value := range values {
+		copied[key] = value
+	}
+
+	valuesMutex.Lock()
+	databaseValues = copied
+	valuesMutex.Unlock()
+	return nil
+}
+
+/*
+SetTenantValues replaces the settings stored for each household (household id -> key -> value).
+They win over every other source, only for that household and only for the Household settings.
+*/
+func SetTenantValues(values map[uint]map[string]string) error {
+	copied := make(map[uint]map[string]string, len(values))
+	for tenantID, settings := range values {
+		copied[tenantID] = make(map[string]string, len(settings))
+		for key, value := range settings {
+			if err := ValidateTenantValue(key, value); err != nil {
+				return err
+			}
+			copied[tenantID][key] = value
+		}
+	}
+
+	valuesMutex.Lock()
+	tenantValues = copied
+	valuesMutex.Unlock()
+	return nil
+}
+
+// ValidateTenantValue checks a value before it is stored for a household
+func ValidateTenantValue(key string, value string) error {
+	if err := ValidateRuntimeValue(key, value); err != nil {
+		return err
+	}
+	if !settingsByKey[key].Household {
+		return fmt.Errorf("%s is shared by every household, only an admin of the default household can change it", key)
+	}
+	return nil
+}
+
+// ValidateRuntimeValue checks a value before it is stored in the database, empty values are rejected since deleting the setting resets it
+func ValidateRuntimeValue(key string, value string) error {
+	setting, ok := LookupSetting(key)
+	if !ok || setting.Secret {
+		return fmt.Errorf("unknown setting %s", key)
+	}
+	if !setting.Runtime {
+		return fmt.Errorf("%s is read at startup, change it in the config file or the environment", key)
+	}
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("%s can not be empty, delete it to go back to the config file, env or default value", key)
+	}
+	if err := setting.ValidateValue(value); err != nil {
+		return fmt.Errorf("%s %w", key, err)
+	}
+	return nil
+}
+
+// Values lists the settings that are not secret with their effective value
+func Values() []SettingValue {
+	var values []SettingValue
+	for _, setting := range Settings {
+		if setting.Secret {
+			continue
+		}
+		value, source := lookup(setting.Key)
+		values = append(values, SettingValue{Setting: setting, Value: value, Source: source})
+	}
+	return values
+}
+
+// TenantValues lists the settings a household can override with their effective value for it
+func TenantValues(tenantID uint) []SettingValue {
+	values := []SettingValue{}
+	for _, setting := range Settings {
+		if !setting.Household {
+			continue
+		}
+		value, source := lookupTenant(tenantID, setting.Key)
+		values = append(values, SettingValue{Setting: setting, Value: value, Source: source})
+	}
+	return values
+}
+
+// String returns the value of a setting, see Load for the order of the sources
+func String(key string) string {
+	value, _ := lookup(key)
+	return value
+}
+
+// Int returns an int setting, 0 when it is empty
+func Int(key string) int {
+	number, _ := strconv.Atoi(String(key))
+	return number
+}
+
+// Duration returns a duration setting, 0 when it is empty
+func Duration(key string) time.Duration {
+	duration, _ := time.ParseDuration(String(key))
+	return duration
+}
+
+// TenantString returns the value of a setting for a household, its stored value wins over the shared one
+func TenantString(tenantID uint, key string) string {
+	value, _ := lookupTenant(tenantID, key)
+	return value
+}
+
+// TenantInt returns an int setting of a household, 0 when it is empty
+func TenantInt(tenantID uint, key string) int {
+	number, _ := strconv.Atoi(TenantString(tenantID, key))
+	return number
+}
+
+// List returns the trimmed non empty values of a comma separated setting
+func List(key string) []string {
+	var list []string
+	for _, value := range strings.Split(String(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
 	}
+	return list
 }
 
-func GetEnv(key string) string {
-	return os.Getenv(key)
+func lookup(key string) (string, string) {
+	valuesMutex.RLock()
+	defer valuesMutex.RUnlock()
+
+	if value := databaseValues[key]; value != "" {
+		return value, SourceDatabase
+	}
+	if value := os.Getenv(key); value != "" {
+		return value, SourceEnv
+	}
+	if value, ok := fileValues[key]; ok {
+		return value, SourceFile
+	}
+	return settingsByKey[key].Default, SourceDefault
+}
+
+func lookupTenant(tenantID uint, key string) (string, string) {
+	valuesMutex.RLock()
+	value := tenantValues[tenantID][key]
+	valuesMutex.RUnlock()
+
+	// Values stored before a setting stopped being a Household one are ignored
+	if value != "" && settingsByKey[key].Household {
+		return value, SourceTenant
+	}
+	return lookup(key)
+}
+
+// readConfigFile parses the JSON config file into strings, a missing file is an empty layer
+func readConfigFile(path string) (map[string]string, error) {
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if _, ok := LookupSetting(key); !ok {
+			return nil, fmt.Errorf("unknown setting %s in %s", key, path)
+		}
+		switch typed := value.(type) {
+		case string:
+			values[key] = typed
+		case float64:
+			values[key] = strconv.FormatFloat(typed, 'f', -1, 64)
+		case bool:
+			values[key] = strconv.FormatBool(typed)
+		case []interface{}: // lists, e.g. "CORS_ALLOWED_ORIGINS": ["https://a", "https://b"]
+			items := make([]string, len(typed))
+			for i, item := range typed {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			return nil, fmt.Errorf("invalid value of %s in %s", key, path)
+		}
+	}
+	return values, nil
 }
 
 // Get config from google sheet service
 func GetGoogleSheetsConfig() *GoogleSheetsConfig {
 	return &GoogleSheetsConfig{
-		Type:                GetEnv("GS_TYPE"),
-		ProjectID:           GetEnv("GS_PROJECT_ID"),
-		PrivateKeyID:        GetEnv("GS_PRIVATE_KEY_ID"),
-		PrivateKey:          strings.ReplaceAll(GetEnv("GS_PRIVATE_KEY"), `\n`, "\n"),
-		ClientEmail:         GetEnv("GS_CLIENT_EMAIL"),
-		ClientID:            GetEnv("GS_CLIENT_ID"),
-		AuthURI:             GetEnv("GS_AUTH_URI"),
-		TokenURI:            GetEnv("GS_TOKEN_URI"),
-		AuthProviderCertURL: GetEnv("GS_AUTH_PROVIDER_CERT_URL"),
-		ClientCertURL:       GetEnv("GS_CLIENT_CERT_URL"),
-		UniverseDomain:      GetEnv("GS_UNIVERSE_DOMAIN"),
+		Type:                String("GS_TYPE"),
+		ProjectID:           String("GS_PROJECT_ID"),
+		PrivateKeyID:        String("GS_PRIVATE_KEY_ID"),
+		PrivateKey:          strings.ReplaceAll(String("GS_PRIVATE_KEY"), `\n`, "\n"),
+		ClientEmail:         String("GS_CLIENT_EMAIL"),
+		ClientID:            String("GS_CLIENT_ID"),
+		AuthURI:             String("GS_AUTH_URI"),
+		TokenURI:            String("GS_TOKEN_URI"),
+		AuthProviderCertURL: String("GS_AUTH_PROVIDER_CERT_URL"),
+		ClientCertURL:       String("GS_CLIENT_CERT_URL"),
+		UniverseDomain:      String("GS_UNIVERSE_DOMAIN"),
 	}
 }
 
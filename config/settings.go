@@ -0,0 +1,170 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of setting values, checked by ValidateValue
+const (
+	KindString   = "string"
+	KindInt      = "int"      // positive integer
+	KindDuration = "duration" // Go duration, e.g. "6h"
+	KindURL      = "url"      // http(s) URL
+	KindList     = "list"     // comma separated values
+	KindMap      = "map"      // "key:value" pairs, comma separated
+)
+
+// Setting describes a configuration value, named like its env variable
+type Setting struct {
+	Key         string             `json:"key"`
+	Kind        string             `json:"kind"`
+	Default     string             `json:"default"`
+	Description string             `json:"description"`
+	Required    bool               `json:"required"`
+	Runtime     bool               `json:"runtime"`   // can be changed from the API, the others are read at startup
+	Household   bool               `json:"household"` // runtime settings each household can override for itself, never server paths or outbound URLs
+	Secret      bool               `json:"-"`         // never listed nor stored in the database
+	validate    func(string) error // extra checks after the kind ones
+}
+
+// Settings are the values the server reads, see Load for where they come from
+var Settings = []Setting{
+	// Server
+	{Key: "PORT", Kind: KindInt, Default: "8080", Description: "HTTP port"},
+	{Key: "CORS_ALLOWED_ORIGINS", Kind: KindList, Description: "origins allowed by CORS, e.g. https://casapipis.net"},
+	{Key: "ANOMALY_SCAN_INTERVAL", Kind: KindDuration, Description: "how often the anomaly analysis runs, disabled when empty"},
+
+	// Databases of the default household
+	{Key: "TRANSACTIONS_DB_PATH", Kind: KindString, Required: true, Description: "SQLite file of the sheet expenses and incomes"},
+	{Key: "CARDS_DB_PATH", Kind: KindString, Required: true, Description: "SQLite file of the card statements, users and households"},
+	{Key: "TRANSACTION_DB", Kind: KindString, Default: "transactions", Description: "name of the transactions connection"},
+	{Key: "CARDS_DB", Kind: KindString, Default: "cards", Description: "name of the cards connection"},
+	{Key: "TENANTS_PATH", Kind: KindString, Default: "tenants", Description: "folder with the databases of the other households"},
+
+	// Authentication
+	{Key: "AUTH_SESSION_SECRET", Kind: KindString, Required: true, Secret: true, validate: minLength(32)},
+	{Key: "AUTH_SESSION_DURATION", Kind: KindDuration, Default: "12h", Runtime: true, Description: "how long a login lasts"},
+	{Key: "AUTH_ADMIN_USERNAME", Kind: KindString, Description: "first user, created when there are no users"},
+	{Key: "AUTH_ADMIN_PASSWORD", Kind: KindString, Secret: true},
+
+	// Google Sheets
+	{Key: "GS_SPREADSHEET_ID", Kind: KindString, Runtime: true, Description: "spreadsheet synced for the default household"},
+	{Key: "GS_SHEET_ID", Kind: KindString, Runtime: true, Description: "sheet synced for the default household"},
+	{Key: "GS_TYPE", Kind: KindString, Description: "service account"},
+	{Key: "GS_PROJECT_ID", Kind: KindString, Description: "service account"},
+	{Key: "GS_PRIVATE_KEY_ID", Kind: KindString, Secret: true},
+	{Key: "GS_PRIVATE_KEY", Kind: KindString, Secret: true},
+	{Key: "GS_CLIENT_EMAIL", Kind: KindString, Description: "service account, share the spreadsheets with it"},
+	{Key: "GS_CLIENT_ID", Kind: KindString, Description: "service account"},
+	{Key: "GS_AUTH_URI", Kind: KindString, Description: "service account"},
+	{Key: "GS_TOKEN_URI", Kind: KindString, Description: "service account"},
+	{Key: "GS_AUTH_PROVIDER_CERT_URL", Kind: KindString, Description: "service account"},
+	{Key: "GS_CLIENT_CERT_URL", Kind: KindString, Description: "service account"},
+	{Key: "GS_UNIVERSE_DOMAIN", Kind: KindString, Description: "service account"},
+
+	// Card statements
+	{Key: "CARD_VISA_PATH", Kind: KindString, Runtime: true, Description: "folder with the Visa statements of the default household"},
+	{Key: "CARD_VISA_ISSUER", Kind: KindString, Default: "bbva", Runtime: true, Description: "parser of the Visa statements"},
+	{Key: "CARD_MASTERCARD_PATH", Kind: KindString, Runtime: true, Description: "folder with the Mastercard statements of the default household"},
+	{Key: "CARD_MASTERCARD_ISSUER", Kind: KindString, Default: "bbva", Runtime: true, Description: "parser of the Mastercard statements"},
+	{Key: "CARD_STATEMENT_PATHS", Kind: KindList, Runtime: true, Description: "extra issuer:card_type:path folders of the default household", validate: statementPaths},
+	{Key: "CARD_UPLOADS_PATH", Kind: KindString, Default: "uploads", Runtime: true, Description: "folder where uploaded statements are kept"},
+	{Key: "CARD_PAYMENT_TYPE", Kind: KindString, Default: "Tarjeta", Runtime: true, Household: true, Description: "sheet expense type of the card payments"},
+	{Key: "BBVA_PDF_SERVICE", Kind: KindURL, Runtime: true, Description: "service that parses the BBVA statements the native parser can not read"},
+
+	// Merchant mappings, they only seed an empty table of the default household
+	{Key: "SUBSCRIPTION_MAP", Kind: KindMap, Runtime: true, Description: "keyword:label subscriptions"},
+	{Key: "SUBSCRIPTION_LOGO_MAP", Kind: KindMap, Runtime: true, Description: "label:logo of the subscriptions"},
+	{Key: "SPECIFIC_EXPENSES_MAP", Kind: KindMap, Runtime: true, Description: "keyword:label specific expenses"},
+	{Key: "SPECIFIC_LOGO_MAP", Kind: KindMap, Runtime: true, Description: "label:logo of the specific expenses"},
+
+	// Attachments
+	{Key: "ATTACHMENTS_STORAGE", Kind: KindString, Default: "local", Description: "storage backend of the attachments", validate: oneOf("local")},
+	{Key: "ATTACHMENTS_PATH", Kind: KindString, Default: "attachments", Description: "folder of the local attachments storage"},
+	{Key: "ATTACHMENT_MAX_SIZE_MB", Kind: KindInt, Default: "10", Runtime: true, Household: true, Description: "maximum size of an attachment"},
+}
+
+var settingsByKey = func() map[string]Setting {
+	byKey := make(map[string]Setting, len(Settings))
+	for _, setting := range Settings {
+		byKey[setting.Key] = setting
+	}
+	return byKey
+}()
+
+// LookupSetting returns the description of a setting
+func LookupSetting(key string) (Setting, bool) {
+	setting, ok := settingsByKey[key]
+	return setting, ok
+}
+
+// ValidateValue checks a value against the kind of the setting, empty values are only rejected for required settings
+func (s Setting) ValidateValue(value string) error {
+
+	if value == "" {
+		if s.Required {
+			return errors.New("is required")
+		}
+		return nil
+	}
+
+	switch s.Kind {
+	case KindInt:
+		if number, err := strconv.Atoi(value); err != nil || number <= 0 {
+			return errors.New("must be a positive integer")
+		}
+	case KindDuration:
+		if duration, err := time.ParseDuration(value); err != nil || duration <= 0 {
+			return errors.New("must be a positive duration, e.g. 30m or 6h")
+		}
+	case KindURL:
+		if parsed, err := url.Parse(value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return errors.New("must be an http or https URL")
+		}
+	case KindMap:
+		for _, pair := range strings.Split(value, ",") {
+			if strings.TrimSpace(pair) != "" && !strings.Contains(pair, ":") {
+				return fmt.Errorf("invalid pair %q, expected key:value", pair)
+			}
+		}
+	}
+
+	if s.validate != nil {
+		return s.validate(value)
+	}
+	return nil
+}
+
+func minLength(length int) func(string) error {
+	return func(value string) error {
+		if len(value) < length {
+			return fmt.Errorf("must be at least %d characters", length)
+		}
+		return nil
+	}
+}
+
+func oneOf(values ...string) func(string) error {
+	return func(value string) error {
+		for _, allowed := range values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	}
+}
+
+func statementPaths(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) != "" && len(strings.SplitN(entry, ":", 3)) != 3 {
+			return fmt.Errorf("invalid entry %q, expected issuer:card_type:path", entry)
+		}
+	}
+	return nil
+}
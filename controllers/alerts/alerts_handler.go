@@ -0,0 +1,123 @@
+package alerts
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	transactions "finance-backend/controllers/base"
+)
+
+type AlertsController struct {
+	*transactions.BaseController // Embed base to share base methods
+}
+
+func NewAlertsController() *AlertsController {
+	return &AlertsController{
+		BaseController: &transactions.BaseController{},
+	}
+}
+
+/*
+GetAnomalies lists the stored anomalies, newest first
+- status: open (default) | acknowledged | dismissed | all
+- kind / source: optional filters (outlier, duplicate, first_merchant / expense, card)
+*/
+func (ec *AlertsController) GetAnomalies(c *gin.Context) {
+
+	status := c.DefaultQuery("status", "open")
+	kind := c.Query("kind")
+	source := c.Query("source")
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Model(&models.Anomaly{})
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	anomalies := []models.Anomaly{}
+	if err := query.Order("detected_at DESC, id DESC").Find(&anomalies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, anomalies)
+}
+
+// ScanAnomalies runs the analysis job on demand
+func (ec *AlertsController) ScanAnomalies(c *gin.Context) {
+
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cardsDB, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := services.ScanAnomalies(db, cardsDB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"new_anomalies": created})
+}
+
+func (ec *AlertsController) AcknowledgeAnomaly(c *gin.Context) {
+	ec.resolveAnomaly(c, "acknowledged")
+}
+
+func (ec *AlertsController) DismissAnomaly(c *gin.Context) {
+	ec.resolveAnomaly(c, "dismissed")
+}
+
+func (ec *AlertsController) resolveAnomaly(c *gin.Context, status string) {
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var anomaly models.Anomaly
+	if err := db.First(&anomaly, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "anomaly not found"})
+		return
+	}
+
+	now := time.Now()
+	anomaly.Status = status
+	anomaly.ResolvedAt = &now
+	if err := db.Save(&anomaly).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, anomaly)
+}
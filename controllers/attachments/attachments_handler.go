@@ -0,0 +1,307 @@
+package attachments
+
+import (
+	"errors"
+	"finance-backend/models"
+	"finance-backend/services"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	transactions "finance-backend/controllers/base"
+)
+
+const attachmentFormOverhead = 1 << 20 // multipart headers and the source and key fields
+
+type AttachmentsController struct {
+	*transactions.BaseController // Embed base to share base methods
+}
+
+func NewAttachmentsController() *AttachmentsController {
+	return &AttachmentsController{
+		BaseController: &transactions.BaseController{},
+	}
+}
+
+type AttachmentItem struct {
+	models.Attachment
+	FormattedSize string `json:"formatted_size"`
+	DownloadURL   string `json:"download_url"`
+	ThumbnailURL  string `json:"thumbnail_url,omitempty"` // only for images
+}
+
+/*
+GetAttachments lists the attachments of a record
+- source: expense | income | card
+- key: UUID, or "document/holder/position" of a card line item
+*/
+func (ec *AttachmentsController) GetAttachments(c *gin.Context) {
+
+	source, key := c.Query("source"), c.Query("key")
+	if !services.ValidRecordSource(source) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid source, expected expense, income or card"})
+		return
+	}
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key is required"})
+		return
+	}
+	if !ec.canAccessRecord(c, source, key) {
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var attachments []models.Attachment
+	if err := db.Where("source = ? AND record_key = ?", source, key).Order("created_at ASC").Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	items := make([]AttachmentItem, len(attachments))
+	for i, attachment := range attachments {
+		items[i] = ec.attachmentItem(attachment)
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+/*
+UploadAttachment stores a receipt or invoice for a record
+- multipart form: file, source (expense | income | card) and key (UUID or "document/holder/position")
+Accepts JPEG, PNG, GIF and WebP images and PDFs up to ATTACHMENT_MAX_SIZE_MB (10 by default),
+the type is detected from the content. Images get a thumbnail.
+*/
+func (ec *AttachmentsController) UploadAttachment(c *gin.Context) {
+
+	maxSize := services.MaxAttachmentSize(ec.CurrentTenant(c))
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+attachmentFormOverhead)
+	if _, err := c.MultipartForm(); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("%s, the limit is %d MB", services.ErrAttachmentTooLarge, maxSize>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	source, key := c.PostForm("source"), c.PostForm("key")
+	if !services.ValidRecordSource(source) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid source, expected expense, income or card"})
+		return
+	}
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key is required"})
+		return
+	}
+	if !ec.canAccessRecord(c, source, key) {
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if fileHeader.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("%s, the limit is %d MB", services.ErrAttachmentTooLarge, maxSize>>20)})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transactionsDB, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cardsDB, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := services.ExistingRecordKeys(transactionsDB, cardsDB, source, []string{key})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(existing) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	storage, err := services.NewAttachmentStorage(ec.CurrentTenant(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	attachment, err := services.SaveAttachment(cardsDB, storage, maxSize, source, key, fileHeader.Filename, content)
+	switch {
+	case errors.Is(err, services.ErrAttachmentTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("%s, the limit is %d MB", err, maxSize>>20)})
+		return
+	case errors.Is(err, services.ErrAttachmentType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ec.attachmentItem(attachment))
+}
+
+/*
+DownloadAttachment returns the stored file
+- thumbnail: "true" to get the JPEG thumbnail of an image
+- inline: "true" to show it in the browser instead of downloading it
+*/
+func (ec *AttachmentsController) DownloadAttachment(c *gin.Context) {
+
+	attachment, _, ok := ec.findAttachment(c)
+	if !ok {
+		return
+	}
+	if !ec.canAccessRecord(c, attachment.Source, attachment.RecordKey) {
+		return
+	}
+
+	storageKey, contentType, fileName := attachment.StorageKey, attachment.ContentType, attachment.FileName
+	if c.Query("thumbnail") == "true" {
+		if attachment.ThumbnailKey == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "attachment has no thumbnail"})
+			return
+		}
+		storageKey, contentType, fileName = attachment.ThumbnailKey, "image/jpeg", "thumbnail-"+fileName+".jpg"
+	}
+
+	storage, err := services.NewAttachmentStorage(ec.CurrentTenant(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := storage.Open(storageKey)
+	if errors.Is(err, services.ErrAttachmentFileNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	disposition := "attachment"
+	if c.Query("inline") == "true" {
+		disposition = "inline"
+	}
+
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, -1, contentType, file, map[string]string{
+		"Content-Disposition": mime.FormatMediaType(disposition, map[string]string{"filename": fileName}),
+	})
+}
+
+// DeleteAttachment removes an attachment and its files
+func (ec *AttachmentsController) DeleteAttachment(c *gin.Context) {
+
+	attachment, db, ok := ec.findAttachment(c)
+	if !ok {
+		return
+	}
+	if !ec.canAccessRecord(c, attachment.Source, attachment.RecordKey) {
+		return
+	}
+
+	storage, err := services.NewAttachmentStorage(ec.CurrentTenant(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.DeleteAttachments(db, storage, []models.Attachment{attachment}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": attachment.ID})
+}
+
+// findAttachment loads the attachment of the :id param, answering the request when it is invalid or missing
+func (ec *AttachmentsController) findAttachment(c *gin.Context) (models.Attachment, *gorm.DB, bool) {
+
+	var attachment models.Attachment
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return attachment, nil, false
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return attachment, nil, false
+	}
+
+	if err := db.Where("id = ?", id).Limit(1).Find(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return attachment, nil, false
+	}
+	if attachment.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return attachment, nil, false
+	}
+	return attachment, db, true
+}
+
+// canAccessRecord answers with a forbidden error when the user cannot see the record: incomes need read_incomes, card line items a visible holder
+func (ec *AttachmentsController) canAccessRecord(c *gin.Context, source string, key string) bool {
+	user, _ := ec.CurrentUser(c)
+	if source == services.RecordSourceIncome && !services.HasPermission(user, services.PermissionReadIncomes) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "your role does not allow " + services.PermissionReadIncomes})
+		return false
+	}
+	if source == services.RecordSourceCard && !services.CanSeeHolder(services.AllowedHolders(user), services.CardKeyHolder(key)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot see this holder"})
+		return false
+	}
+	return true
+}
+
+func (ec *AttachmentsController) attachmentItem(attachment models.Attachment) AttachmentItem {
+	item := AttachmentItem{
+		Attachment:    attachment,
+		FormattedSize: fmt.Sprintf("%.1f KB", float64(attachment.Size)/1024),
+		DownloadURL:   fmt.Sprintf("/attachments/%d", attachment.ID),
+	}
+	if attachment.ThumbnailKey != "" {
+		item.ThumbnailURL = item.DownloadURL + "?thumbnail=true"
+	}
+	return item
+}
@@ -0,0 +1,491 @@
+package auth
+
+import (
+	"errors"
+	"finance-backend/models"
+	"finance-backend/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	transactions "finance-backend/controllers/base"
+)
+
+type AuthController struct {
+	*transactions.BaseController // Embed base to share base methods
+}
+
+func NewAuthController() *AuthController {
+	return &AuthController{
+		BaseController: &transactions.BaseController{},
+	}
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type UserRequest struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Role     string   `json:"role"`      // viewer (default) | editor | admin
+	Holders  []string `json:"holders"`   // card holders the user can see, all of them when empty
+	TenantID uint     `json:"tenant_id"` // household of the user, the own one by default, only the default household can pick another
+}
+
+type UserUpdateRequest struct {
+	Role     *string   `json:"role"`
+	Holders  *[]string `json:"holders"`
+	Password *string   `json:"password"` // resets the password and ends the sessions of the user
+}
+
+type PasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type APITokenRequest struct {
+	Name          string `json:"name"`
+	ExpiresInDays int    `json:"expires_in_days"` // 0 for a token that does not expire
+}
+
+/*
+Login exchanges a username and password for a session token
+- body: {"username", "password"}
+The token goes in the "Authorization: Bearer <token>" header and lasts AUTH_SESSION_DURATION (12h by default).
+*/
+func (ec *AuthController) Login(c *gin.Context) {
+
+	var request LoginRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, token, expiresAt, err := services.Login(db, request.Username, request.Password)
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "token_type": "Bearer", "expires_at": expiresAt, "user": user})
+}
+
+// GetMe returns the authenticated user
+func (ec *AuthController) GetMe(c *gin.Context) {
+	user, _ := ec.CurrentUser(c)
+	c.JSON(http.StatusOK, user)
+}
+
+/*
+ChangePassword replaces the password of the authenticated user and ends their other sessions
+- body: {"current_password", "new_password"}, a new session token is returned
+*/
+func (ec *AuthController) ChangePassword(c *gin.Context) {
+
+	user, _ := ec.CurrentUser(c)
+
+	var request PasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !services.CheckPassword(user, request.CurrentPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+		return
+	}
+	if err := services.ValidatePassword(request.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.SetPassword(&user, request.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Model(&user).Select("password_hash", "token_version").Updates(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, expiresAt, err := services.IssueSessionToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "token_type": "Bearer", "expires_at": expiresAt})
+}
+
+// GetUsers lists the accounts of the household, every account for the admins of the default household
+func (ec *AuthController) GetUsers(c *gin.Context) {
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	users := []models.User{}
+	if err := ec.scopeUsers(c, db.Preload("Holders").Preload("Tenant")).Order("username ASC").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+/*
+CreateUser creates an account for another household member
+- body: {"username", "password", "role", "holders", "tenant_id"}, passwords need at least 10 characters
+- role: viewer (default) reads expenses and card data, editor also reads incomes and reports, edits and syncs, admin also manages users
+- holders: restricts the user to the card line items of those holders
+- tenant_id: household of the user, the admins of the default household can create the first admin of another one
+*/
+func (ec *AuthController) CreateUser(c *gin.Context) {
+
+	var request UserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	current, _ := ec.CurrentUser(c)
+	user := models.User{Username: request.Username, Role: request.Role, Holders: userHolders(request.Holders), TenantID: current.TenantID}
+	if user.Role == "" {
+		user.Role = services.RoleViewer
+	}
+	if err := services.ValidateCredentials(&user, request.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateUserAccess(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.TenantID != 0 && request.TenantID != current.TenantID {
+		if !services.IsDefaultTenant(ec.CurrentTenant(c)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the admins of the default household can create users in other households"})
+			return
+		}
+		user.TenantID = request.TenantID
+	}
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tenant models.Tenant
+	if err := db.Where("id = ?", user.TenantID).Limit(1).Find(&tenant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tenant.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "household not found"})
+		return
+	}
+
+	var existing int64
+	if err := db.Model(&models.User{}).Where("username = ?", user.Username).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "a user with that username already exists"})
+		return
+	}
+
+	if err := services.SetPassword(&user, request.Password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.Create(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	user.Tenant = &tenant
+
+	c.JSON(http.StatusCreated, user)
+}
+
+/*
+UpdateUser changes the role, holders or password of an account, the fields left out are kept
+- body: {"role", "holders", "password"}
+*/
+func (ec *AuthController) UpdateUser(c *gin.Context) {
+
+	user, db, ok := ec.findUser(c)
+	if !ok {
+		return
+	}
+
+	var request UserUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previousRole := user.Role
+	if request.Role != nil {
+		user.Role = *request.Role
+	}
+	if request.Holders != nil {
+		user.Holders = userHolders(*request.Holders)
+	}
+	if err := services.ValidateUserAccess(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if previousRole == services.RoleAdmin && user.Role != services.RoleAdmin && ec.lastAdmin(c, db, user.TenantID) {
+		return
+	}
+	if request.Password != nil {
+		if err := services.ValidatePassword(*request.Password); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := services.SetPassword(&user, *request.Password); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Select("role", "password_hash", "token_version").Updates(&user).Error; err != nil {
+			return err
+		}
+		if request.Holders == nil {
+			return nil
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserHolder{}).Error; err != nil {
+			return err
+		}
+		for i := range user.Holders {
+			user.Holders[i].UserID = user.ID
+		}
+		if len(user.Holders) == 0 {
+			return nil
+		}
+		return tx.Create(&user.Holders).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser removes an account with its API tokens, users cannot delete themselves
+func (ec *AuthController) DeleteUser(c *gin.Context) {
+
+	user, db, ok := ec.findUser(c)
+	if !ok {
+		return
+	}
+
+	if current, _ := ec.CurrentUser(c); current.ID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot delete your own user"})
+		return
+	}
+	if user.Role == services.RoleAdmin && ec.lastAdmin(c, db, user.TenantID) {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.APIToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserHolder{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": user.ID})
+}
+
+// GetAPITokens lists the personal API tokens of the authenticated user (without their values)
+func (ec *AuthController) GetAPITokens(c *gin.Context) {
+
+	user, _ := ec.CurrentUser(c)
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens := []models.APIToken{}
+	if err := db.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+/*
+CreateAPIToken creates a personal API token for scripts, acting as the authenticated user
+- body: {"name", "expires_in_days"} (0 or missing for a token that does not expire)
+The token value is only returned in this response.
+*/
+func (ec *AuthController) CreateAPIToken(c *gin.Context) {
+
+	user, _ := ec.CurrentUser(c)
+
+	var request APITokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(request.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if request.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expires_in_days"})
+		return
+	}
+
+	var expiresAt *time.Time
+	if request.ExpiresInDays > 0 {
+		expiration := time.Now().AddDate(0, 0, request.ExpiresInDays)
+		expiresAt = &expiration
+	}
+
+	apiToken, plain, err := services.NewAPIToken(user.ID, request.Name, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Create(&apiToken).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"token": plain, "api_token": apiToken})
+}
+
+// DeleteAPIToken revokes a personal API token of the authenticated user
+func (ec *AuthController) DeleteAPIToken(c *gin.Context) {
+
+	user, _ := ec.CurrentUser(c)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := db.Where("id = ? AND user_id = ?", id, user.ID).Delete(&models.APIToken{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": id})
+}
+
+// findUser loads the user of the :id param with their holders and household, answering the request when it is invalid or missing
+func (ec *AuthController) findUser(c *gin.Context) (models.User, *gorm.DB, bool) {
+
+	var user models.User
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return user, nil, false
+	}
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return user, nil, false
+	}
+
+	if err := ec.scopeUsers(c, db.Preload("Holders").Preload("Tenant")).Where("id = ?", id).Limit(1).Find(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return user, nil, false
+	}
+	if user.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return user, nil, false
+	}
+	return user, db, true
+}
+
+// scopeUsers restricts a users query to the household of the authenticated user, the admins of the default household manage every household
+func (ec *AuthController) scopeUsers(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if services.IsDefaultTenant(ec.CurrentTenant(c)) {
+		return query
+	}
+	current, _ := ec.CurrentUser(c)
+	return query.Where("tenant_id = ?", current.TenantID)
+}
+
+// lastAdmin answers with a conflict when there is a single admin left in the household, so it cannot be left without one
+func (ec *AuthController) lastAdmin(c *gin.Context, db *gorm.DB, tenantID uint) bool {
+	var admins int64
+	if err := db.Model(&models.User{}).Where("tenant_id = ? AND role = ?", tenantID, services.RoleAdmin).Count(&admins).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return true
+	}
+	if admins <= 1 {
+		c.JSON(http.StatusConflict, gin.H{"error": "there must be at least one admin in the household"})
+		return true
+	}
+	return false
+}
+
+func userHolders(names []string) []models.UserHolder {
+	holders := make([]models.UserHolder, len(names))
+	for i, name := range names {
+		holders[i] = models.UserHolder{Holder: name}
+	}
+	return holders
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"errors"
+	"finance-backend/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	transactions "finance-backend/controllers/base"
+)
+
+/*
+RequireAuth rejects the requests without a valid "Authorization: Bearer <token>" header,
+the token being a session from /auth/login or a personal API token.
+The user is stored in the context, see BaseController.CurrentUser.
+*/
+func (ec *AuthController) RequireAuth(c *gin.Context) {
+
+	scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="finance-backend"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := services.Authenticate(db, strings.TrimSpace(token))
+	if errors.Is(err, services.ErrInvalidToken) {
+		c.Header("WWW-Authenticate", `Bearer realm="finance-backend", error="invalid_token"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Set(transactions.UserContextKey, user)
+	c.Next()
+}
+
+/*
+Require rejects the requests of users whose role does not grant the permission,
+used after RequireAuth, e.g. r.GET("/incomes", authController.Require(services.PermissionReadIncomes), ...)
+*/
+func (ec *AuthController) Require(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := ec.CurrentUser(c)
+		if !services.HasPermission(user, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "your role does not allow " + permission})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAllHolders rejects the users restricted to some holders, for data that adds up every holder (statement totals, settlements)
+func (ec *AuthController) RequireAllHolders(c *gin.Context) {
+	if ec.AllowedHolders(c) != nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "only available to users that can see every holder"})
+		return
+	}
+	c.Next()
+}
+
+// RequireDefaultTenant rejects the users of the other households, for the routes that manage every household
+func (ec *AuthController) RequireDefaultTenant(c *gin.Context) {
+	if !services.IsDefaultTenant(ec.CurrentTenant(c)) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "only available to the default household"})
+		return
+	}
+	c.Next()
+}
+
+// RequireHolderParam rejects the requests for a holder (route param) the user cannot see
+func (ec *AuthController) RequireHolderParam(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !services.CanSeeHolder(ec.AllowedHolders(c), c.Param(param)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "you cannot see this holder"})
+			return
+		}
+		c.Next()
+	}
+}
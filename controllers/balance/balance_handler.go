@@ -1,13 +1,17 @@
 package balance
 
 import (
+	"errors"
 	"finance-backend/models"
+	"finance-backend/services"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 
 	transactions "finance-backend/controllers/base"
 )
@@ -42,16 +46,19 @@ type SyncIncomeData struct {
 func (ec *BalanceController) GetBalance(c *gin.Context) {
 
 	type Balance struct {
-		Balance                  float64 `json:"balance"`
-		FormattedBalance         string  `json:"formatted_balance"`
-		TotalExpenses            float64 `json:"total_expenses"`
-		FormattedExpenses        string  `json:"formatted_expenses"`
-		TotalIncomes             float64 `json:"total_incomes"`
-		FormattedIncomes         string  `json:"formatted_incomes"`
-		FormattedMonthlyIncome   string  `json:"formatted_monthly_income"`
-		FormattedMonthlyExpenses string  `json:"formatted_monthly_expenses"`
-		FormattedMonthlyCardsARS string  `json:"formatted_monthly_cards_ars"`
-		FormattedMonthlyCardsUSD string  `json:"formatted_monthly_cards_usd"`
+		Balance                  float64  `json:"balance"`
+		FormattedBalance         string   `json:"formatted_balance"`
+		TotalExpenses            float64  `json:"total_expenses"`
+		FormattedExpenses        string   `json:"formatted_expenses"`
+		TotalIncomes             float64  `json:"total_incomes"`
+		FormattedIncomes         string   `json:"formatted_incomes"`
+		FormattedMonthlyIncome   string   `json:"formatted_monthly_income"`
+		FormattedMonthlyExpenses string   `json:"formatted_monthly_expenses"`
+		FormattedMonthlyCardsARS string   `json:"formatted_monthly_cards_ars"`
+		FormattedMonthlyCardsUSD string   `json:"formatted_monthly_cards_usd"`
+		FormattedMonthlyPayments string   `json:"formatted_monthly_card_payments"`
+		AdjustedTo               string   `json:"adjusted_to,omitempty"`
+		MonthsWithoutCPI         []string `json:"months_without_cpi,omitempty"` // left out of the adjusted all-time totals
 	}
 
 	// Response structs for db query
@@ -63,6 +70,10 @@ func (ec *BalanceController) GetBalance(c *gin.Context) {
 		Total float64
 	}
 
+	var sumMonthlyPayments []struct {
+		Total float64
+	}
+
 	var sumMonthlyIncome []struct {
 		Total float64
 	}
@@ -87,16 +98,23 @@ func (ec *BalanceController) GetBalance(c *gin.Context) {
 
 	dateFilter := fmt.Sprintf("%04d-%02d", year, month)
 
+	// Optional inflation adjustment: ?adjust=real&base=YYYY-MM
+	adjuster, err := ec.GetInflationAdjuster(c, c.Query("adjust"), c.Query("base"))
+	if err != nil {
+		c.JSON(ec.InflationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
 	var incomes []models.Incomes
 	var expenses []models.Expenses
 
-	db, err := ec.GetDatabaseInstance("TRANSACTION_DB")
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	cardsDB, err := ec.GetDatabaseInstance("CARDS_DB")
+	cardsDB, err := ec.GetDatabaseInstance(c, "CARDS_DB")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -118,13 +136,21 @@ func (ec *BalanceController) GetBalance(c *gin.Context) {
 
 	// ---------- Monthly expenses ----------
 
+	// Card payments reconciled with their statement are transfers, the spending is in the monthly cards totals
 	query := db.Model(&expenses).Select("sum(amount) as total").Where("strftime('%Y-%m', date) = ?", dateFilter)
-	err = query.Find(&sumMonthlyExpenses).Error
+	err = query.Where("transfer = ?", false).Find(&sumMonthlyExpenses).Error
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := db.Model(&expenses).Select("sum(amount) as total").
+		Where("strftime('%Y-%m', date) = ? AND transfer = ?", dateFilter, true).
+		Find(&sumMonthlyPayments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	// ---------- Monthly income ----------
 
 	new_month_format := monthStr
@@ -161,6 +187,53 @@ func (ec *BalanceController) GetBalance(c *gin.Context) {
 
 	expensesAmount := totalExpenses[0].Total
 	incomesAmount := totalIncome[0].Total
+	monthlyIncome := sumMonthlyIncome[0].Total
+	monthlyExpenses := sumMonthlyExpenses[0].Total
+
+	// ---------- Split expenses ----------
+
+	// With ?member=NAME split expenses and card line items only count the share of that member
+	monthlyCardsARS := totalMonthltyCards[0].TotalArs
+	var splitAdjustments map[string]float64
+	if member := c.Query("member"); member != "" {
+		splitAdjustments, err = services.SplitAdjustments(db, cardsDB, member)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, amount := range splitAdjustments {
+			expensesAmount -= amount
+		}
+		monthlyExpenses -= splitAdjustments[dateFilter]
+
+		cardAdjustments, err := services.CardSplitAdjustments(cardsDB, member)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		monthlyCardsARS -= cardAdjustments[dateFilter]
+	}
+	monthlyPayments := sumMonthlyPayments[0].Total
+
+	// ---------- Inflation adjustment ----------
+
+	// Historical totals mix pesos of every month, so they are restated month by month
+	var monthsWithoutCPI []string
+	if adjuster != nil {
+		expensesAmount, incomesAmount, monthsWithoutCPI, err = ec.realTotals(db, adjuster, splitAdjustments)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, amount := range []*float64{&monthlyIncome, &monthlyExpenses, &monthlyCardsARS, &monthlyPayments} {
+			*amount, err = adjuster.Adjust(*amount, dateFilter)
+			if err != nil {
+				c.JSON(ec.InflationErrorStatus(err), gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
 	currentBalance := incomesAmount - expensesAmount
 
 	balance := Balance{
@@ -170,15 +243,85 @@ func (ec *BalanceController) GetBalance(c *gin.Context) {
 		FormattedExpenses:        ec.FormatAmount(expensesAmount),
 		TotalIncomes:             incomesAmount,
 		FormattedIncomes:         ec.FormatAmount(incomesAmount),
-		FormattedMonthlyIncome:   ec.FormatAmount(sumMonthlyIncome[0].Total),
-		FormattedMonthlyExpenses: ec.FormatAmount(sumMonthlyExpenses[0].Total),
-		FormattedMonthlyCardsARS: ec.FormatAmount(totalMonthltyCards[0].TotalArs),
+		FormattedMonthlyIncome:   ec.FormatAmount(monthlyIncome),
+		FormattedMonthlyExpenses: ec.FormatAmount(monthlyExpenses),
+		FormattedMonthlyCardsARS: ec.FormatAmount(monthlyCardsARS),
 		FormattedMonthlyCardsUSD: ec.FormatAmount(totalMonthltyCards[0].TotalUsd),
+		FormattedMonthlyPayments: ec.FormatAmount(monthlyPayments),
+	}
+	if adjuster != nil {
+		balance.AdjustedTo = adjuster.BaseMonth
+		balance.MonthsWithoutCPI = monthsWithoutCPI
 	}
 
 	c.JSON(http.StatusOK, balance)
 }
 
+/*
+realTotals returns all-time expenses (minus the monthly split adjustments) and incomes restated in pesos of the adjuster base month.
+The months before the first CPI index can not be restated, they are left out of both totals and returned sorted.
+*/
+func (ec *BalanceController) realTotals(db *gorm.DB, adjuster *services.InflationAdjuster, splitAdjustments map[string]float64) (float64, float64, []string, error) {
+
+	var monthlyExpenses []struct {
+		Period string
+		Total  float64
+	}
+
+	if err := db.Model(&models.Expenses{}).
+		Select("strftime('%Y-%m', date) as period, sum(amount) as total").
+		Group("period").
+		Find(&monthlyExpenses).Error; err != nil {
+		return 0, 0, nil, fmt.Errorf("error fetching monthly expenses at realTotals(): %w", err)
+	}
+
+	uncovered := make(map[string]bool)
+	adjust := func(amount float64, period string) (float64, error) {
+		adjusted, err := adjuster.Adjust(amount, period)
+		if errors.Is(err, services.ErrNoCPIIndex) {
+			uncovered[period] = true
+			return 0, nil
+		}
+		return adjusted, err
+	}
+
+	var expensesTotal float64
+	for _, row := range monthlyExpenses {
+		amount, err := adjust(row.Total-splitAdjustments[row.Period], row.Period)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		expensesTotal += amount
+	}
+
+	// Incomes keep date_time in several layouts, so they are grouped after parsing
+	var incomes []models.Incomes
+	if err := db.Select("date_time, amount").Find(&incomes).Error; err != nil {
+		return 0, 0, nil, fmt.Errorf("error fetching incomes at realTotals(): %w", err)
+	}
+
+	var incomesTotal float64
+	for _, income := range incomes {
+		date, err := ec.ParseTransactionDate(income.DateTime)
+		if err != nil {
+			continue
+		}
+		amount, err := adjust(income.Amount, date.Format("2006-01"))
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		incomesTotal += amount
+	}
+
+	months := make([]string, 0, len(uncovered))
+	for month := range uncovered {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+
+	return expensesTotal, incomesTotal, months, nil
+}
+
 func getCurrentMonthAndYear() (string, string) {
 	now := time.Now()
 	month := fmt.Sprintf("%02d", int(now.Month())) // fuerza dos dígitos
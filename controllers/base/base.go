@@ -1,15 +1,23 @@
 package transactions
 
 import (
-	"finance-backend/config"
+	"errors"
+	"finance-backend/models"
+	"finance-backend/services"
+	"finance-backend/utils"
 	"fmt"
+	"net/http"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 	"gorm.io/gorm"
 )
 
+// UserContextKey is where the auth middleware stores the authenticated user
+const UserContextKey = "user"
+
 /*
 BaseController provides shared transaction functionality:
 - DB connection management
@@ -19,16 +27,16 @@ Embed this in specific controllers (expenses/incomes) to avoid duplication.
 type BaseController struct{}
 
 /*
-GetDatabaseInstance returns an instance of a database
+GetDatabaseInstance returns an instance of a database of the household of the authenticated user
 - database string, parameter checks name on .env file
 */
-func (b *BaseController) GetDatabaseInstance(database string) (*gorm.DB, error) {
-	databaseName := config.GetEnv(database)
-	db, ok := config.DBs[databaseName]
-	if !ok {
-		return nil, fmt.Errorf("database not available")
-	}
-	return db, nil
+func (b *BaseController) GetDatabaseInstance(c *gin.Context, database string) (*gorm.DB, error) {
+	return services.TenantDatabase(b.CurrentTenant(c), database)
+}
+
+// GetSystemDatabase returns the database shared by every household, with the users, API tokens and households
+func (b *BaseController) GetSystemDatabase() (*gorm.DB, error) {
+	return services.SystemDatabase()
 }
 
 func (b *BaseController) FormatAmount(amount float64) string {
@@ -49,3 +57,107 @@ func (b *BaseController) FormatDateResume(dateStr string) (time.Time, error) {
 	layout := "02-Jan-06"
 	return time.Parse(layout, dateStr)
 }
+
+// ParseTransactionDate parses the date_time column of expenses/incomes, see utils.ParseTransactionDate
+func (b *BaseController) ParseTransactionDate(dateStr string) (time.Time, error) {
+	return utils.ParseTransactionDate(dateStr)
+}
+
+/*
+GetInflationAdjuster builds the CPI adjuster for the adjust/base query params
+- adjust string, "real" restates amounts in constant pesos, "" or "nominal" keeps them as is (returns nil)
+- base string, YYYY-MM month whose pesos are used, defaults to the current month
+*/
+func (b *BaseController) GetInflationAdjuster(c *gin.Context, adjust string, base string) (*services.InflationAdjuster, error) {
+	if adjust == "" || adjust == "nominal" {
+		return nil, nil
+	}
+	if adjust != "real" {
+		return nil, fmt.Errorf("%w: adjust must be real or nominal", services.ErrInvalidAdjustment)
+	}
+	if base == "" {
+		base = time.Now().Format("2006-01")
+	}
+
+	db, err := b.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		return nil, err
+	}
+	return services.NewInflationAdjuster(db, base)
+}
+
+// InflationErrorStatus is 400 for invalid adjust/base params and months without CPI data, 500 for the rest
+func (b *BaseController) InflationErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvalidAdjustment) || errors.Is(err, services.ErrNoCPIIndex) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+/*
+PruneAttachments removes the attachments of the records of a source that no longer exist,
+called after a sync deletes records
+- source string, expense | income | card
+*/
+func (b *BaseController) PruneAttachments(c *gin.Context, source string) (int, error) {
+
+	transactionsDB, err := b.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		return 0, err
+	}
+
+	cardsDB, err := b.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		return 0, err
+	}
+
+	storage, err := services.NewAttachmentStorage(b.CurrentTenant(c))
+	if err != nil {
+		return 0, err
+	}
+	return services.PruneAttachments(transactionsDB, cardsDB, storage, source)
+}
+
+/*
+DeleteRecordAttachments removes the attachments of some records, e.g. the line items missing
+from a replaced statement, whose keys can be taken by other items of the new version
+- source string, expense | income | card
+*/
+func (b *BaseController) DeleteRecordAttachments(c *gin.Context, source string, keys []string) (int, error) {
+
+	cardsDB, err := b.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		return 0, err
+	}
+
+	storage, err := services.NewAttachmentStorage(b.CurrentTenant(c))
+	if err != nil {
+		return 0, err
+	}
+	return services.DeleteRecordAttachments(cardsDB, storage, source, keys)
+}
+
+// CurrentUser returns the user authenticated by the auth middleware
+func (b *BaseController) CurrentUser(c *gin.Context) (models.User, bool) {
+	value, ok := c.Get(UserContextKey)
+	if !ok {
+		return models.User{}, false
+	}
+	user, ok := value.(models.User)
+	return user, ok
+}
+
+// CurrentTenant returns the household of the authenticated user, a zero value (no databases) without one
+func (b *BaseController) CurrentTenant(c *gin.Context) models.Tenant {
+	user, _ := b.CurrentUser(c)
+	if user.Tenant == nil {
+		return models.Tenant{}
+	}
+	return *user.Tenant
+}
+
+// AllowedHolders returns the card holders the authenticated user is restricted to, nil when they can see every holder
+func (b *BaseController) AllowedHolders(c *gin.Context) []string {
+	user, _ := b.CurrentUser(c)
+	return services.AllowedHolders(user)
+}
@@ -7,14 +7,17 @@ import (
 	"finance-backend/services"
 	"finance-backend/utils"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type CardsController struct {
@@ -28,6 +31,7 @@ func NewCardsController() *CardsController {
 }
 
 type resumePaths struct {
+	Issuer   string `json:"issuer"` // bank whose statement parser reads the file
 	CardLogo string `json:"cardLogo"`
 	FilePath string `json:"filePath"`
 	FileName string `json:"fileName"`
@@ -40,11 +44,15 @@ type ResumeDetails struct {
 }
 
 type ResumesData struct {
-	CardLogo   string          `json:"cardLogo"`
-	FileName   string          `json:"fileName"`
-	Hash       string          `json:"hash"`
-	ResumeData []ResumeDetails `json:"resumeData"`
-	Totals     services.Totals `json:"totals"`
+	CardLogo        string          `json:"cardLogo"`
+	FileName        string          `json:"fileName"`
+	Hash            string          `json:"hash"`
+	ResumeData      []ResumeDetails `json:"resumeData"`
+	Totals          services.Totals `json:"totals"`
+	ClosingDate     time.Time       `json:"closingDate"`
+	DueDate         time.Time       `json:"dueDate"`
+	MinimumPayment  float64         `json:"minimumPayment"`
+	PreviousBalance float64         `json:"previousBalance"`
 }
 
 type SubscriptionSummary struct {
@@ -54,12 +62,6 @@ type SubscriptionSummary struct {
 	LogoName             string  `json:"logo_name"`
 }
 
-type subscriptionQueryResult struct {
-	Servicio             string  `gorm:"column:servicio"`
-	ReferenceDescription string  `gorm:"column:reference_description"`
-	TotalAmount          float64 `gorm:"column:total_amount"`
-}
-
 type cuotasAboutToExpire struct {
 	Description     string  `gorm:"column:description"`
 	FormattedAmount string  `gorm:"column:formatted_amount"`
@@ -89,30 +91,33 @@ func (ec *CardsController) GetCuotasAboutToExpire(c *gin.Context) {
 	}
 	targetMonth := fmt.Sprintf("%04d-%02d", yearInt, monthInt)
 
-	subscriptionMap := utils.LoadMap("SPECIFIC_EXPENSES_MAP")
-	//subscriptionLogoMap := utils.LoadLogosMap("SPECIFIC_LOGO_MAP")
-
-	if len(subscriptionMap) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "subscription map is empty"})
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	db, err := ec.GetDatabaseInstance("CARDS_DB")
-	if err != nil {
+	// Same requirement as before the mappings moved to the database (SPECIFIC_EXPENSES_MAP)
+	var specificMappings int64
+	if err := db.Model(&models.MerchantMapping{}).Where("kind = ?", "specific").Count(&specificMappings).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if specificMappings == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "there are no specific expense mappings"})
+		return
+	}
 
 	var rawResults []cuotasAboutToExpire
 	var finalResults []CuotasAboutToExpireSummary
 
-	// Ejecutar query
-	tx := db.Table("holder_expenses AS e").
+	// Installments with at most one cuota left after this statement (parsed when the resume was synced)
+	tx := services.ScopeHolders(db.Table("holder_expenses AS e"), ec.AllowedHolders(c), "e.holder").
 		Select("e.description, e.amount, e.formatted_amount").
 		Joins("JOIN holders h ON e.document_number = h.document_number AND e.holder = h.holder").
 		Joins("JOIN resumes r ON h.document_number = r.document_number").
 		Where("strftime('%Y-%m', r.resume_date) = ?", targetMonth).
-		Where(" LOWER(e.description) LIKE '%C.%'").
+		Where("e.installment_total > 0 AND e.installment_total - e.installment_number < 2").
 		Order("amount DESC").
 		Scan(&rawResults)
 
@@ -122,21 +127,12 @@ func (ec *CardsController) GetCuotasAboutToExpire(c *gin.Context) {
 	}
 
 	for _, row := range rawResults {
-
-		pendingCuota, err := getCuotasFromDescription(row.Description)
-
-		if err != nil {
-			continue
-		}
-
-		if pendingCuota < 2 && pendingCuota > -1 {
-			finalResults = append(finalResults, CuotasAboutToExpireSummary{
-				Description:     row.Description,
-				Amount:          row.Amount,
-				FormattedAmount: row.FormattedAmount,
-				LogoName:        "",
-			})
-		}
+		finalResults = append(finalResults, CuotasAboutToExpireSummary{
+			Description:     row.Description,
+			Amount:          row.Amount,
+			FormattedAmount: row.FormattedAmount,
+			LogoName:        "",
+		})
 	}
 
 	c.JSON(http.StatusOK, finalResults)
@@ -158,74 +154,27 @@ func (ec *CardsController) GetSpecificCardExpenes(c *gin.Context) {
 	}
 	targetMonth := fmt.Sprintf("%04d-%02d", yearInt, monthInt)
 
-	subscriptionMap := utils.LoadMap("SPECIFIC_EXPENSES_MAP")
-	subscriptionLogoMap := utils.LoadLogosMap("SPECIFIC_LOGO_MAP")
-
-	if len(subscriptionMap) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "subscription map is empty"})
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// CASE WHEN dinámico
-	caseExpr := "CASE\n"
-	for keyword, label := range subscriptionMap {
-		caseExpr += fmt.Sprintf("  WHEN LOWER(e.description) LIKE '%%%s%%' THEN '%s'\n", keyword, label)
-	}
-	caseExpr += "  ELSE 'otro'\nEND AS servicio"
-
-	// WHERE dinámico
-	var likeConditions []string
-	var likeParams []interface{}
-	for keyword := range subscriptionMap {
-		likeConditions = append(likeConditions, "LOWER(e.description) LIKE ?")
-		likeParams = append(likeParams, fmt.Sprintf("%%%s%%", keyword))
-	}
-	whereClause := "(" + strings.Join(likeConditions, " OR ") + ")"
-
-	db, err := ec.GetDatabaseInstance("CARDS_DB")
+	matcher, err := services.NewMerchantMatcher(db, "specific")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	var rawResults []subscriptionQueryResult
-	var finalResults []SubscriptionSummary
-
-	// Ejecutar query
-	tx := db.Table("holder_expenses AS e").
-		Select(caseExpr+", MAX(e.description) AS reference_description, SUM(e.amount) AS total_amount").
-		Joins("JOIN holders h ON e.document_number = h.document_number AND e.holder = h.holder").
-		Joins("JOIN resumes r ON h.document_number = r.document_number").
-		Where("strftime('%Y-%m', r.resume_date) = ?", targetMonth).
-		Where(whereClause, likeParams...).
-		Group("servicio").
-		Order("total_amount DESC").
-		Scan(&rawResults)
-
-	if tx.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error.Error()})
+	if matcher.Len() == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "subscription map is empty"})
 		return
 	}
 
-	for _, row := range rawResults {
-		service := row.Servicio
-		if strings.Contains(strings.ToUpper(row.ReferenceDescription), "USD") && !strings.Contains(service, "USD") {
-			service += " USD"
-		}
-
-		// Logo se basa en el nombre sin el " USD"
-		baseLogoKey := strings.Replace(service, " USD", "", 1)
-		logo := subscriptionLogoMap[baseLogoKey]
-		if logo == "" {
-			logo = "default.png"
-		}
-
-		finalResults = append(finalResults, SubscriptionSummary{
-			Servicio:             service,
-			TotalAmount:          row.TotalAmount,
-			TotalAmountFormatted: ec.FormatAmount(row.TotalAmount),
-			LogoName:             logo,
-		})
+	finalResults, err := ec.summarizeMappedExpenses(db, matcher, targetMonth, ec.AllowedHolders(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	c.JSON(http.StatusOK, finalResults)
@@ -247,77 +196,102 @@ func (ec *CardsController) GetSubscriptionSummary(c *gin.Context) {
 	}
 	targetMonth := fmt.Sprintf("%04d-%02d", yearInt, monthInt)
 
-	subscriptionMap := utils.LoadMap("SUBSCRIPTION_MAP")
-	subscriptionLogoMap := utils.LoadLogosMap("SUBSCRIPTION_LOGO_MAP")
-
-	if len(subscriptionMap) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "subscription map is empty"})
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// CASE WHEN dinámico
-	caseExpr := "CASE\n"
-	for keyword, label := range subscriptionMap {
-		caseExpr += fmt.Sprintf("  WHEN LOWER(e.description) LIKE '%%%s%%' THEN '%s'\n", keyword, label)
+	matcher, err := services.NewMerchantMatcher(db, "subscription")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	caseExpr += "  ELSE 'otro'\nEND AS servicio"
 
-	// WHERE dinámico
-	var likeConditions []string
-	var likeParams []interface{}
-	for keyword := range subscriptionMap {
-		likeConditions = append(likeConditions, "LOWER(e.description) LIKE ?")
-		likeParams = append(likeParams, fmt.Sprintf("%%%s%%", keyword))
+	if matcher.Len() == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "subscription map is empty"})
+		return
 	}
-	whereClause := "(" + strings.Join(likeConditions, " OR ") + ")"
 
-	db, err := ec.GetDatabaseInstance("CARDS_DB")
+	finalResults, err := ec.summarizeMappedExpenses(db, matcher, targetMonth, ec.AllowedHolders(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	var rawResults []subscriptionQueryResult
-	var finalResults []SubscriptionSummary
+	c.JSON(http.StatusOK, finalResults)
+}
 
-	// Ejecutar query
-	tx := db.Table("holder_expenses AS e").
-		Select(caseExpr+", MAX(e.description) AS reference_description, SUM(e.amount) AS total_amount").
+/*
+summarizeMappedExpenses adds up the line items of a statement month matched by the
+mappings, grouped by label. Labels charged in dollars get a " USD" suffix.
+- holders: only the line items of these holders, every holder when nil
+*/
+func (ec *CardsController) summarizeMappedExpenses(db *gorm.DB, matcher *services.MerchantMatcher, targetMonth string, holders []string) ([]SubscriptionSummary, error) {
+
+	var items []struct {
+		Description string
+		Amount      float64
+	}
+
+	if err := services.ScopeHolders(db.Table("holder_expenses AS e"), holders, "e.holder").
+		Select("e.description, e.amount").
 		Joins("JOIN holders h ON e.document_number = h.document_number AND e.holder = h.holder").
 		Joins("JOIN resumes r ON h.document_number = r.document_number").
 		Where("strftime('%Y-%m', r.resume_date) = ?", targetMonth).
-		Where(whereClause, likeParams...).
-		Group("servicio").
-		Order("total_amount DESC").
-		Scan(&rawResults)
+		Scan(&items).Error; err != nil {
+		return nil, err
+	}
 
-	if tx.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error.Error()})
-		return
+	type labelTotal struct {
+		ReferenceDescription string
+		Logo                 string
+		TotalAmount          float64
 	}
 
-	for _, row := range rawResults {
-		service := row.Servicio
-		if strings.Contains(strings.ToUpper(row.ReferenceDescription), "USD") && !strings.Contains(service, "USD") {
+	totals := make(map[string]*labelTotal)
+	var labels []string
+	for _, item := range items {
+		mapping, ok := matcher.Match(item.Description)
+		if !ok {
+			continue
+		}
+		total := totals[mapping.Label]
+		if total == nil {
+			total = &labelTotal{Logo: mapping.Logo}
+			totals[mapping.Label] = total
+			labels = append(labels, mapping.Label)
+		}
+		total.TotalAmount += item.Amount
+		if item.Description > total.ReferenceDescription {
+			total.ReferenceDescription = item.Description
+		}
+	}
+
+	sort.SliceStable(labels, func(i, j int) bool { return totals[labels[i]].TotalAmount > totals[labels[j]].TotalAmount })
+
+	var finalResults []SubscriptionSummary
+	for _, label := range labels {
+		total := totals[label]
+		service := label
+		if strings.Contains(strings.ToUpper(total.ReferenceDescription), "USD") && !strings.Contains(service, "USD") {
 			service += " USD"
 		}
 
-		// Logo se basa en el nombre sin el " USD"
-		baseLogoKey := strings.Replace(service, " USD", "", 1)
-		logo := subscriptionLogoMap[baseLogoKey]
+		logo := total.Logo
 		if logo == "" {
 			logo = "default.png"
 		}
 
 		finalResults = append(finalResults, SubscriptionSummary{
 			Servicio:             service,
-			TotalAmount:          row.TotalAmount,
-			TotalAmountFormatted: ec.FormatAmount(row.TotalAmount),
+			TotalAmount:          total.TotalAmount,
+			TotalAmountFormatted: ec.FormatAmount(total.TotalAmount),
 			LogoName:             logo,
 		})
 	}
 
-	c.JSON(http.StatusOK, finalResults)
+	return finalResults, nil
 }
 
 func (ec *CardsController) GetCardsExpenses(c *gin.Context) {
@@ -354,7 +328,7 @@ func (ec *CardsController) GetCardsExpenses(c *gin.Context) {
 	}
 
 	// Build query with strftime
-	db, err := ec.GetDatabaseInstance("CARDS_DB")
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -378,6 +352,24 @@ func (ec *CardsController) GetCardsExpenses(c *gin.Context) {
 
 	}
 
+	// Users restricted to some holders only get those, with the statement totals of what they can see
+	if allowed := ec.AllowedHolders(c); allowed != nil {
+		for i := range resumes {
+			visibleHolders := []models.Holder{}
+			resumes[i].TotalARS, resumes[i].TotalUSD, resumes[i].MinimumPayment, resumes[i].PreviousBalance = 0, 0, 0, 0
+			for _, h := range resumes[i].Holders {
+				if services.CanSeeHolder(allowed, h.Holder) {
+					visibleHolders = append(visibleHolders, h)
+					resumes[i].TotalARS += h.TotalARS
+					resumes[i].TotalUSD += h.TotalUSD
+				}
+			}
+			resumes[i].Holders = visibleHolders
+			resumes[i].FormattedTotalARS = ec.FormatAmount(resumes[i].TotalARS)
+			resumes[i].FormattedTotalUSD = ec.FormatAmount(resumes[i].TotalUSD)
+		}
+	}
+
 	// Filter holders in memory if specified
 
 	var noHolders = false
@@ -407,34 +399,59 @@ func (ec *CardsController) GetCardsExpenses(c *gin.Context) {
 	c.JSON(http.StatusOK, resumes)
 }
 
-func (ec *CardsController) SyncResumes(c *gin.Context) {
+type resumeSyncStatus struct {
+	ResumeDate string `json:"resumeDate"`
+	Hash       string `json:"hash"`
+	Message    string `json:"message"`
+	CardType   string `json:"cardType"`
+}
 
-	type JSONResponse struct {
-		ResumeDate string `json:"resumeDate"`
-		Hash       string `json:"hash"`
-		Message    string `json:"message"`
-		CardType   string `json:"cardType"`
-	}
+func (ec *CardsController) SyncResumes(c *gin.Context) {
 
-	var resumes []models.Resume
-	var holders []models.Holder
-	var holdersExpenses []models.HolderExpense
-	var response []JSONResponse
+	tenant := ec.CurrentTenant(c)
+	resumesPath, err := getResumesFilePath(tenant)
 
-	resumesPath, err := getResumesFilePath()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
+	resumesParsedData, err := getResumeData(tenant, resumesPath)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	resumesParsedData, err := getResumeData(resumesPath)
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// ---------- Database records population ----------
+	// replace=true stores the new version of statements whose file changed
+	response, created := ec.storeResumes(c, db, ec.buildResumes(resumesParsedData), c.Query("replace") == "true")
+
+	if created == 0 {
+		c.JSON(http.StatusOK, gin.H{"Resumes sync status": response})
+		return
+	}
+
+	// New statements may start, continue or cancel recurring charges
+	detection, err := services.DetectSubscriptions(db)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"Resumes sync status": response, "Subscriptions detection error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"Resumes sync status": response, "Subscriptions detection": detection})
+}
+
+// buildResumes converts the parsed statements into resume records, skipping the ones without a period
+func (ec *CardsController) buildResumes(resumesParsedData []ResumesData) []models.Resume {
+
+	var resumes []models.Resume
+	var holders []models.Holder
+	var holdersExpenses []models.HolderExpense
 
 	//Todo - Maybe change this logic to avoid this triple nested loop, for our use case it is not a problem ATM
 	for _, resume := range resumesParsedData {
@@ -443,14 +460,18 @@ func (ec *CardsController) SyncResumes(c *gin.Context) {
 
 			for _, expense := range holder.Expenses {
 
+				installmentNumber, installmentTotal, _ := utils.ParseInstallment(expense.Description)
+
 				holdersExpenses = append(holdersExpenses, models.HolderExpense{
-					DocumentNumber:  resume.Hash,
-					Holder:          holder.Holder,
-					Position:        len(holdersExpenses) + 1,
-					Date:            expense.Date.Format("2006-01-02"), // Convert to string in YYYY-MM-DD format
-					Description:     expense.Description,
-					Amount:          expense.Amount,
-					FormattedAmount: ec.FormatAmount(expense.Amount),
+					DocumentNumber:    resume.Hash,
+					Holder:            holder.Holder,
+					Position:          len(holdersExpenses) + 1,
+					Date:              expense.Date.Format("2006-01-02"), // Convert to string in YYYY-MM-DD format
+					Description:       expense.Description,
+					Amount:            expense.Amount,
+					FormattedAmount:   ec.FormatAmount(expense.Amount),
+					InstallmentNumber: installmentNumber,
+					InstallmentTotal:  installmentTotal,
 				})
 			}
 
@@ -468,7 +489,11 @@ func (ec *CardsController) SyncResumes(c *gin.Context) {
 
 		}
 
+		// Files not named "MM-YYYY" use the month of the statement closing date
 		resumeDate, err := parseMonthYear(resume.FileName)
+		if err != nil && !resume.ClosingDate.IsZero() {
+			resumeDate, err = time.Date(resume.ClosingDate.Year(), resume.ClosingDate.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+		}
 		if err != nil {
 			continue // Skip this resume if date parsing fails
 		}
@@ -477,7 +502,13 @@ func (ec *CardsController) SyncResumes(c *gin.Context) {
 			DocumentNumber:    resume.Hash,
 			Holders:           holders,
 			CardType:          resume.CardLogo,
+			FileHash:          resume.Hash,
+			Version:           1,
 			ResumeDate:        resumeDate.Format("2006-01-02"), // Convert to string in YYYY-MM-DD format
+			ClosingDate:       formatOptionalDate(resume.ClosingDate),
+			DueDate:           formatOptionalDate(resume.DueDate),
+			MinimumPayment:    resume.MinimumPayment,
+			PreviousBalance:   resume.PreviousBalance,
 			TotalARS:          resume.Totals.ARS,
 			FormattedTotalARS: ec.FormatAmount(resume.Totals.ARS),
 			TotalUSD:          resume.Totals.USD,
@@ -488,71 +519,119 @@ func (ec *CardsController) SyncResumes(c *gin.Context) {
 
 	}
 
-	// ---------- Database insertions ----------
+	return resumes
+}
 
-	db, err := ec.GetDatabaseInstance("CARDS_DB")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+/*
+storeResumes inserts the resumes not stored yet and links their installment plans
+- a statement is identified by card type and resume date, the same file is never imported twice
+- a different file for a stored statement replaces it only when replace is set
+- returns the status of every resume and how many were created or replaced
+*/
+func (ec *CardsController) storeResumes(c *gin.Context, db *gorm.DB, resumes []models.Resume, replace bool) ([]resumeSyncStatus, int) {
+
+	var response []resumeSyncStatus
+
+	status := func(resume models.Resume, message string) {
+		response = append(response, resumeSyncStatus{
+			CardType:   resume.CardType,
+			ResumeDate: resume.ResumeDate,
+			Hash:       resume.DocumentNumber,
+			Message:    message,
+		})
 	}
 
+	stored := 0
 	for _, resume := range resumes {
 		//check if the resume already exists on database
 		existingResume := models.Resume{}
-		db.Where("document_number = ?", resume.DocumentNumber).First(&existingResume)
+		db.Where("document_number = ?", resume.DocumentNumber).Limit(1).Find(&existingResume)
+		if existingResume.DocumentNumber == "" {
+			db.Where("card_type = ? AND resume_date = ?", resume.CardType, resume.ResumeDate).Limit(1).Find(&existingResume)
+		}
 
 		if existingResume.DocumentNumber != "" {
-			response = append(response, JSONResponse{
-				CardType:   resume.CardType,
-				ResumeDate: resume.ResumeDate,
-				Hash:       resume.DocumentNumber,
-				Message:    "Resume already exists",
-			})
-		} else {
-			result := db.Model(&models.Resume{}).Create(&resume)
-			if result.Error != nil {
-				response = append(response, JSONResponse{
-					CardType:   resume.CardType,
-					ResumeDate: resume.ResumeDate,
-					Hash:       resume.DocumentNumber,
-					Message:    "Error creating resume",
-				})
-			} else {
-				response = append(response, JSONResponse{
-					CardType:   resume.CardType,
-					ResumeDate: resume.ResumeDate,
-					Hash:       resume.DocumentNumber,
-					Message:    "Resume created successfully",
-				})
+			sameFile := existingResume.DocumentNumber == resume.DocumentNumber || existingResume.FileHash == resume.FileHash
+
+			switch {
+			case sameFile:
+				status(resume, "Resume already exists")
+			case !replace && existingResume.FileHash == "":
+				// Imported before file hashes were stored, there is nothing to compare against
+				status(resume, "Resume already exists")
+			case !replace:
+				status(resume, "Resume already exists with a different file, sync it with replace=true to store the new version")
+			default:
+				replaced, dropped, err := services.ReplaceResume(db, existingResume, resume)
+				if err != nil {
+					status(resume, "Error replacing resume: "+err.Error())
+					continue
+				}
+				// Line items missing from the new version lose their attachments, the others moved with them
+				if _, err := ec.DeleteRecordAttachments(c, services.RecordSourceCard, dropped); err != nil {
+					status(replaced, fmt.Sprintf("Resume replaced, version %d, error removing attachments: %v", replaced.Version, err))
+				} else {
+					status(replaced, fmt.Sprintf("Resume replaced, version %d, %d line items removed", replaced.Version, len(dropped)))
+				}
+				stored++
 			}
+			continue
+		}
 
+		result := db.Model(&models.Resume{}).Create(&resume)
+		if result.Error != nil {
+			status(resume, "Error creating resume")
+		} else if err := services.LinkInstallmentPlans(db, resume); err != nil {
+			status(resume, "Resume created, error linking installment plans: "+err.Error())
+		} else {
+			status(resume, "Resume created successfully")
+			stored++
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"Resumes sync status": response})
+	return response, stored
 }
 
-func getResumesFilePath() ([]resumePaths, error) {
+/*
+getResumesFilePath lists the statement PDFs of every configured directory
+- CARD_VISA_PATH / CARD_MASTERCARD_PATH: read with the CARD_VISA_ISSUER / CARD_MASTERCARD_ISSUER parser (bbva by default)
+- CARD_STATEMENT_PATHS: extra "issuer:card_type:path" entries, comma separated, e.g. "galicia:visa galicia:/data/galicia"
+The other households only read the statement_paths entries of the household, in the same format.
+*/
+func getResumesFilePath(tenant models.Tenant) ([]resumePaths, error) {
 
 	type directoriesPath struct {
+		issuer   string
 		path     string
 		cardLogo string
 	}
 
 	var resumesPath []resumePaths
-	directories := make([]directoriesPath, 2)
-	directoryInfo := directoriesPath{
-		path:     config.GetEnv("CARD_VISA_PATH"),
-		cardLogo: "visa",
-	}
-	directories[0] = directoryInfo
+	var directories []directoriesPath
 
-	directoryInfo = directoriesPath{
-		path:     config.GetEnv("CARD_MASTERCARD_PATH"),
-		cardLogo: "mastercard",
+	if services.IsDefaultTenant(tenant) {
+		if path := config.String("CARD_VISA_PATH"); path != "" {
+			directories = append(directories, directoriesPath{issuer: config.String("CARD_VISA_ISSUER"), path: path, cardLogo: "visa"})
+		}
+		if path := config.String("CARD_MASTERCARD_PATH"); path != "" {
+			directories = append(directories, directoriesPath{issuer: config.String("CARD_MASTERCARD_ISSUER"), path: path, cardLogo: "mastercard"})
+		}
 	}
 
-	directories[1] = directoryInfo
+	for _, entry := range strings.Split(services.TenantValue(tenant, tenant.StatementPaths, "CARD_STATEMENT_PATHS"), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid CARD_STATEMENT_PATHS entry %q, expected issuer:card_type:path", entry)
+		}
+		directories = append(directories, directoriesPath{
+			issuer:   strings.ToLower(strings.TrimSpace(parts[0])),
+			cardLogo: strings.ToLower(strings.TrimSpace(parts[1])),
+			path:     strings.TrimSpace(parts[2]),
+		})
+	}
 
 	for _, dir := range directories {
 		entries, err := os.ReadDir(dir.path)
@@ -564,8 +643,8 @@ func getResumesFilePath() ([]resumePaths, error) {
 				continue
 			}
 			completePath := dir.path + "/" + v.Name()
-			fmt.Println(completePath)
 			resumesPath = append(resumesPath, resumePaths{
+				Issuer:   dir.issuer,
 				CardLogo: dir.cardLogo,
 				FilePath: completePath,
 				FileName: v.Name()[:len(v.Name())-len(filepath.Ext(v.Name()))],
@@ -577,53 +656,78 @@ func getResumesFilePath() ([]resumePaths, error) {
 	return resumesPath, nil
 }
 
-func getResumeData(paths []resumePaths) ([]ResumesData, error) {
+func getResumeData(tenant models.Tenant, paths []resumePaths) ([]ResumesData, error) {
 
 	var ResumeData []ResumesData
-	var ResumeDetail []ResumeDetails
 
-	bbvaReader, err := services.NewPdfReaderBBVA()
-	if err != nil {
-		return nil, fmt.Errorf("error trying to create a BBVA PDF reader instance at getResumeData(): %w", err)
-	}
+	// One parser per issuer, created on first use
+	parsers := make(map[string]services.StatementParser)
 
 	for _, path := range paths {
-		holders, totals, hash, err := bbvaReader.ReadResumes(services.ResumePath{
-			CardLogo: path.CardLogo,
-			FilePath: path.FilePath,
-			FileName: path.FileName,
-		})
-
-		if err != nil {
-			fmt.Println("Error abriendo el archivo:", err)
-			continue
-		}
-
-		for _, holder := range holders {
-			detail := ResumeDetails{
-				Holder:   holder.Holder,
-				Expenses: holder.Expenses,
-				Totals:   holder.Totals,
+		parser, ok := parsers[path.Issuer]
+		if !ok {
+			var err error
+			parser, err = services.NewStatementParser(tenant, path.Issuer)
+			if err != nil {
+				return nil, fmt.Errorf("error trying to create a statement parser at getResumeData(): %w", err)
 			}
-			ResumeDetail = append(ResumeDetail, detail)
+			parsers[path.Issuer] = parser
 		}
 
-		header := ResumesData{
-			CardLogo:   path.CardLogo,
-			FileName:   path.FileName,
-			Hash:       hash,
-			ResumeData: ResumeDetail,
-			Totals:     totals,
+		header, err := readResumeFile(parser, path)
+		if err != nil {
+			log.Printf("error reading statement %s: %v", path.FilePath, err)
+			continue
 		}
 
 		ResumeData = append(ResumeData, header)
-		ResumeDetail = nil // Reset for next iteration
 	}
 
 	return ResumeData, nil
 
 }
 
+// readResumeFile parses one statement file with the parser of its issuer
+func readResumeFile(parser services.StatementParser, path resumePaths) (ResumesData, error) {
+
+	statement, err := parser.ReadResumes(services.ResumePath{
+		CardLogo: path.CardLogo,
+		FilePath: path.FilePath,
+		FileName: path.FileName,
+	})
+	if err != nil {
+		return ResumesData{}, err
+	}
+
+	var ResumeDetail []ResumeDetails
+	for _, holder := range statement.Holders {
+		ResumeDetail = append(ResumeDetail, ResumeDetails{
+			Holder:   holder.Holder,
+			Expenses: holder.Expenses,
+			Totals:   holder.Totals,
+		})
+	}
+
+	return ResumesData{
+		CardLogo:        path.CardLogo,
+		FileName:        path.FileName,
+		Hash:            statement.Hash,
+		ResumeData:      ResumeDetail,
+		Totals:          statement.Totals,
+		ClosingDate:     statement.ClosingDate,
+		DueDate:         statement.DueDate,
+		MinimumPayment:  statement.MinimumPayment,
+		PreviousBalance: statement.PreviousBalance,
+	}, nil
+}
+
+func formatOptionalDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+	return date.Format("2006-01-02")
+}
+
 func parseMonthYear(input string) (time.Time, error) {
 	// Formato: "MM-YYYY"
 	layout := "01-2006"
@@ -635,37 +739,3 @@ func parseMonthYear(input string) (time.Time, error) {
 	// Ajustar al primer día del mes
 	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
 }
-func getCuotasFromDescription(description string) (int, error) {
-	cuotaString := "C."
-	runas := []rune(description)
-	var pendiente int
-	var totalCuotas int
-
-	// Buscar la posición de la subcadena
-	pos := strings.Index(description, cuotaString)
-
-	if pos != -1 {
-
-		start := pos + 2
-		end := pos + 7
-		cuota := string(runas[start:end])
-		fmt.Println(cuota)
-		strSplitted := strings.Split(cuota, "/")
-		for i, row := range strSplitted {
-			fmt.Println(row)
-			if i == 0 {
-				numWithZero, _ := strconv.Atoi(row)
-				pendiente = numWithZero
-			}
-			if i == 1 {
-				numWithZero, _ := strconv.Atoi(row)
-				totalCuotas = numWithZero
-			}
-		}
-
-	} else {
-		return -1, fmt.Errorf("Couldn't find any cuota on description")
-	}
-	cuotasFaltantes := totalCuotas - pendiente
-	return cuotasFaltantes, nil
-}
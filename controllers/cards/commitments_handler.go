@@ -0,0 +1,288 @@
+package cards
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"finance-backend/utils"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CommitmentItem struct {
+	Kind            string  `json:"kind"` // installment | subscription
+	Description     string  `json:"description"`
+	Installment     string  `json:"installment,omitempty"` // "04/06"
+	Currency        string  `json:"currency"`              // ARS | USD, USD items are not added to the peso totals
+	Amount          float64 `json:"amount"`
+	FormattedAmount string  `json:"formatted_amount"`
+}
+
+type HolderCommitment struct {
+	Holder                 string           `json:"holder"`
+	Installments           float64          `json:"installments"`
+	Subscriptions          float64          `json:"subscriptions"`
+	Total                  float64          `json:"total"`
+	FormattedInstallments  string           `json:"formatted_installments"`
+	FormattedSubscriptions string           `json:"formatted_subscriptions"`
+	FormattedTotal         string           `json:"formatted_total"`
+	Items                  []CommitmentItem `json:"items"`
+}
+
+type CardCommitment struct {
+	CardType       string             `json:"card_type"`
+	Total          float64            `json:"total"`
+	FormattedTotal string             `json:"formatted_total"`
+	Holders        []HolderCommitment `json:"holders"`
+}
+
+type MonthCommitment struct {
+	Month                  string           `json:"month"`
+	Installments           float64          `json:"installments"`
+	Subscriptions          float64          `json:"subscriptions"`
+	Total                  float64          `json:"total"`
+	FormattedInstallments  string           `json:"formatted_installments"`
+	FormattedSubscriptions string           `json:"formatted_subscriptions"`
+	FormattedTotal         string           `json:"formatted_total"`
+	Cards                  []CardCommitment `json:"cards"`
+}
+
+/*
+GetCardCommitments projects the card bills already committed for the next months
+- months: how many statements ahead to project (defaults to 12)
+Each month adds the installments still to be billed of the active plans and the
+subscriptions (mapped or detected) charged on the latest statement of
+each card, which are assumed to repeat every month.
+*/
+func (ec *CardsController) GetCardCommitments(c *gin.Context) {
+
+	months, err := strconv.Atoi(c.DefaultQuery("months", "12"))
+	if err != nil || months < 1 || months > 60 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid months, expected 1 to 60"})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Latest statement month per card, projections start on the following month
+	var latestRows []struct {
+		CardType string
+		Latest   string
+	}
+
+	if err := db.Model(&models.Resume{}).
+		Select("card_type, MAX(strftime('%Y-%m', resume_date)) as latest").
+		Group("card_type").
+		Find(&latestRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	latestByCard := make(map[string]string, len(latestRows))
+	latest := ""
+	for _, row := range latestRows {
+		latestByCard[row.CardType] = row.Latest
+		if row.Latest > latest {
+			latest = row.Latest
+		}
+	}
+
+	// Bills from the current month on, unless this month's statement is already imported
+	start := time.Now().Format("2006-01")
+	if latest >= start {
+		start = nextMonth(latest)
+	}
+
+	startMonth, _ := time.Parse("2006-01", start)
+	projection := make(map[string]map[string]map[string]*HolderCommitment) // month -> card -> holder
+	var monthKeys []string
+	for i := 0; i < months; i++ {
+		key := startMonth.AddDate(0, i, 0).Format("2006-01")
+		monthKeys = append(monthKeys, key)
+		projection[key] = make(map[string]map[string]*HolderCommitment)
+	}
+
+	add := func(month string, cardType string, holder string, item CommitmentItem) {
+		cards, ok := projection[month]
+		if !ok {
+			return
+		}
+		if cards[cardType] == nil {
+			cards[cardType] = make(map[string]*HolderCommitment)
+		}
+		h := cards[cardType][holder]
+		if h == nil {
+			h = &HolderCommitment{Holder: holder}
+			cards[cardType][holder] = h
+		}
+		item.Currency = "ARS"
+		if strings.Contains(strings.ToUpper(item.Description), "USD") {
+			item.Currency = "USD"
+		}
+		item.FormattedAmount = ec.FormatAmount(item.Amount)
+		h.Items = append(h.Items, item)
+		if item.Currency == "USD" {
+			return
+		}
+		if item.Kind == "installment" {
+			h.Installments += item.Amount
+		} else {
+			h.Subscriptions += item.Amount
+		}
+		h.Total += item.Amount
+	}
+
+	// ---------- Installments ----------
+
+	var plans []models.InstallmentPlan
+	if err := db.Where("last_installment < total_installments").Find(&plans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, plan := range plans {
+		// A plan missing from the latest statement of its card was cancelled or paid off
+		if plan.LastSeenMonth < latestByCard[plan.CardType] {
+			continue
+		}
+		firstMonth, err := time.Parse("2006-01", plan.FirstMonth)
+		if err != nil {
+			continue
+		}
+		for number := plan.LastInstallment + 1; number <= plan.TotalInstallments; number++ {
+			add(firstMonth.AddDate(0, number-1, 0).Format("2006-01"), plan.CardType, plan.Holder, CommitmentItem{
+				Kind:        "installment",
+				Description: strings.ToUpper(plan.Merchant),
+				Installment: fmt.Sprintf("%02d/%02d", number, plan.TotalInstallments),
+				Amount:      plan.InstallmentAmount,
+			})
+		}
+	}
+
+	// ---------- Recurring subscriptions ----------
+
+	matcher, err := services.NewMerchantMatcher(db, "subscription")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	matched := make(map[string]bool) // card|holder|merchant already projected from the mappings
+	for cardType, cardLatest := range latestByCard {
+		if matcher.Len() == 0 {
+			break
+		}
+
+		var items []struct {
+			Holder      string
+			Description string
+			Amount      float64
+		}
+
+		if err := db.Table("holder_expenses AS e").
+			Select("e.holder, e.description, e.amount").
+			Joins("JOIN resumes r ON e.document_number = r.document_number").
+			Where("r.card_type = ? AND strftime('%Y-%m', r.resume_date) = ?", cardType, cardLatest).
+			Where("COALESCE(e.installment_total, 0) = 0").
+			Scan(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, item := range items {
+			mapping, ok := matcher.Match(item.Description)
+			if !ok {
+				continue
+			}
+			label := mapping.Label
+			if strings.Contains(strings.ToUpper(item.Description), "USD") && !strings.Contains(label, "USD") {
+				label += " USD"
+			}
+			for _, month := range monthKeys {
+				add(month, cardType, item.Holder, CommitmentItem{
+					Kind:        "subscription",
+					Description: label,
+					Amount:      item.Amount,
+				})
+			}
+			matched[cardType+"|"+item.Holder+"|"+utils.NormalizeMerchant(item.Description)] = true
+		}
+	}
+
+	// Detected subscriptions still charged on the latest statement of their card
+	var detected []models.Subscription
+	if err := db.Where("status = ?", "active").Find(&detected).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, subscription := range detected {
+		if subscription.LastSeenMonth != latestByCard[subscription.CardType] || matched[subscription.SubscriptionKey] {
+			continue
+		}
+		for _, month := range monthKeys {
+			add(month, subscription.CardType, subscription.Holder, CommitmentItem{
+				Kind:        "subscription",
+				Description: subscription.Description,
+				Amount:      subscription.LastAmount,
+			})
+		}
+	}
+
+	// ---------- Response ----------
+
+	response := make([]MonthCommitment, 0, len(monthKeys))
+	var totalCommitted float64
+
+	for _, month := range monthKeys {
+		monthCommitment := MonthCommitment{Month: month, Cards: []CardCommitment{}}
+
+		for cardType, holders := range projection[month] {
+			card := CardCommitment{CardType: cardType}
+			for _, h := range holders {
+				h.FormattedInstallments = ec.FormatAmount(h.Installments)
+				h.FormattedSubscriptions = ec.FormatAmount(h.Subscriptions)
+				h.FormattedTotal = ec.FormatAmount(h.Total)
+				card.Total += h.Total
+				monthCommitment.Installments += h.Installments
+				monthCommitment.Subscriptions += h.Subscriptions
+				card.Holders = append(card.Holders, *h)
+			}
+			sort.Slice(card.Holders, func(i, j int) bool { return card.Holders[i].Holder < card.Holders[j].Holder })
+			card.FormattedTotal = ec.FormatAmount(card.Total)
+			monthCommitment.Cards = append(monthCommitment.Cards, card)
+		}
+		sort.Slice(monthCommitment.Cards, func(i, j int) bool { return monthCommitment.Cards[i].CardType < monthCommitment.Cards[j].CardType })
+
+		monthCommitment.Total = monthCommitment.Installments + monthCommitment.Subscriptions
+		monthCommitment.FormattedInstallments = ec.FormatAmount(monthCommitment.Installments)
+		monthCommitment.FormattedSubscriptions = ec.FormatAmount(monthCommitment.Subscriptions)
+		monthCommitment.FormattedTotal = ec.FormatAmount(monthCommitment.Total)
+		totalCommitted += monthCommitment.Total
+
+		response = append(response, monthCommitment)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total_committed":           totalCommitted,
+		"formatted_total_committed": ec.FormatAmount(totalCommitted),
+		"months":                    response,
+	})
+}
+
+func nextMonth(month string) string {
+	t, err := time.Parse("2006-01", month)
+	if err != nil {
+		return month
+	}
+	return t.AddDate(0, 1, 0).Format("2006-01")
+}
@@ -0,0 +1,88 @@
+package cards
+
+import (
+	"finance-backend/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CardDue struct {
+	CardType                string  `json:"card_type"`
+	ResumeDate              string  `json:"resume_date"`
+	ClosingDate             string  `json:"closing_date"`
+	DueDate                 string  `json:"due_date"`
+	DaysLeft                int     `json:"days_left"`
+	TotalARS                float64 `json:"total_ars"`
+	FormattedTotalARS       string  `json:"formatted_total_ars"`
+	TotalUSD                float64 `json:"total_usd"`
+	FormattedTotalUSD       string  `json:"formatted_total_usd"`
+	MinimumPayment          float64 `json:"minimum_payment"`
+	FormattedMinimumPayment string  `json:"formatted_minimum_payment"`
+}
+
+/*
+GetUpcomingDues lists the statements due from a date on, soonest first
+- from: YYYY-MM-DD (defaults to today)
+Statements imported without a due date are not listed.
+*/
+func (ec *CardsController) GetUpcomingDues(c *gin.Context) {
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from, expected YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var resumes []models.Resume
+	if err := db.Where("due_date IS NOT NULL AND due_date <> '' AND due_date >= ?", from.Format("2006-01-02")).
+		Order("due_date ASC, card_type ASC").
+		Find(&resumes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := []CardDue{}
+	var totalARS, totalUSD float64
+	for _, resume := range resumes {
+		dueDate, err := time.Parse("2006-01-02", resume.DueDate)
+		if err != nil {
+			continue
+		}
+		totalARS += resume.TotalARS
+		totalUSD += resume.TotalUSD
+		response = append(response, CardDue{
+			CardType:                resume.CardType,
+			ResumeDate:              resume.ResumeDate,
+			ClosingDate:             resume.ClosingDate,
+			DueDate:                 resume.DueDate,
+			DaysLeft:                int(dueDate.Sub(today).Hours() / 24),
+			TotalARS:                resume.TotalARS,
+			FormattedTotalARS:       ec.FormatAmount(resume.TotalARS),
+			TotalUSD:                resume.TotalUSD,
+			FormattedTotalUSD:       ec.FormatAmount(resume.TotalUSD),
+			MinimumPayment:          resume.MinimumPayment,
+			FormattedMinimumPayment: ec.FormatAmount(resume.MinimumPayment),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total_ars":           totalARS,
+		"formatted_total_ars": ec.FormatAmount(totalARS),
+		"total_usd":           totalUSD,
+		"formatted_total_usd": ec.FormatAmount(totalUSD),
+		"dues":                response,
+	})
+}
@@ -0,0 +1,94 @@
+package cards
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InstallmentPlanSummary struct {
+	models.InstallmentPlan
+	FormattedInstallmentAmount string  `json:"formatted_installment_amount"`
+	RemainingInstallments      int     `json:"remaining_installments"`
+	RemainingAmount            float64 `json:"remaining_amount"`
+	FormattedRemainingAmount   string  `json:"formatted_remaining_amount"`
+}
+
+type InstallmentPlansResponse struct {
+	Plans                   []InstallmentPlanSummary `json:"plans"`
+	TotalCommitted          float64                  `json:"total_committed"`
+	FormattedTotalCommitted string                   `json:"formatted_total_committed"`
+}
+
+/*
+GetInstallmentPlans lists the installment plans with their remaining balance
+- status: active (default, plans with cuotas still to be billed) | all
+- card_type / holder: optional filters
+TotalCommitted is the future spend already committed by the listed plans.
+*/
+func (ec *CardsController) GetInstallmentPlans(c *gin.Context) {
+
+	status := c.DefaultQuery("status", "active")
+	cardType := strings.ToLower(c.DefaultQuery("card_type", "all"))
+	holderFilter := strings.ToLower(c.DefaultQuery("holder", "all"))
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := services.ScopeHolders(db.Model(&models.InstallmentPlan{}), ec.AllowedHolders(c), "holder")
+	if status == "active" {
+		query = query.Where("last_installment < total_installments")
+	}
+	if cardType != "all" {
+		query = query.Where("LOWER(card_type) = ?", cardType)
+	}
+	if holderFilter != "all" {
+		query = query.Where("LOWER(holder) = ?", holderFilter)
+	}
+
+	var plans []models.InstallmentPlan
+	if err := query.Order("end_month ASC, id ASC").Find(&plans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := InstallmentPlansResponse{Plans: []InstallmentPlanSummary{}}
+	for _, plan := range plans {
+		remaining := plan.RemainingAmount()
+		response.TotalCommitted += remaining
+		response.Plans = append(response.Plans, InstallmentPlanSummary{
+			InstallmentPlan:            plan,
+			FormattedInstallmentAmount: ec.FormatAmount(plan.InstallmentAmount),
+			RemainingInstallments:      plan.RemainingInstallments(),
+			RemainingAmount:            remaining,
+			FormattedRemainingAmount:   ec.FormatAmount(remaining),
+		})
+	}
+	response.FormattedTotalCommitted = ec.FormatAmount(response.TotalCommitted)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RebuildInstallmentPlans re-parses every stored line item and recreates the installment plans
+func (ec *CardsController) RebuildInstallmentPlans(c *gin.Context) {
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	plans, err := services.RebuildInstallmentPlans(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"installment_plans": plans})
+}
@@ -0,0 +1,138 @@
+package cards
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+GetMerchantMappings lists the keyword/regex -> label -> logo mappings in match order
+- kind: subscription | specific | category | all (default)
+*/
+func (ec *CardsController) GetMerchantMappings(c *gin.Context) {
+
+	kind := c.DefaultQuery("kind", "all")
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Model(&models.MerchantMapping{})
+	if kind != "all" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	mappings := []models.MerchantMapping{}
+	if err := query.Order("kind ASC, priority DESC, LENGTH(pattern) DESC, pattern ASC, id ASC").Find(&mappings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mappings)
+}
+
+/*
+CreateMerchantMapping stores a new mapping
+- body: {"kind", "pattern", "is_regex", "label", "logo", "priority"}
+*/
+func (ec *CardsController) CreateMerchantMapping(c *gin.Context) {
+
+	var mapping models.MerchantMapping
+	if err := c.ShouldBindJSON(&mapping); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mapping.ID = 0
+
+	if err := services.ValidateMerchantMapping(&mapping); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Create(&mapping).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapping)
+}
+
+// UpdateMerchantMapping replaces every field of an existing mapping
+func (ec *CardsController) UpdateMerchantMapping(c *gin.Context) {
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var body models.MerchantMapping
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.ValidateMerchantMapping(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var mapping models.MerchantMapping
+	if err := db.First(&mapping, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "mapping not found"})
+		return
+	}
+
+	body.ID = mapping.ID
+	if err := db.Save(&body).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+func (ec *CardsController) DeleteMerchantMapping(c *gin.Context) {
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := db.Delete(&models.MerchantMapping{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "mapping not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": id})
+}
@@ -0,0 +1,382 @@
+package cards
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CardPaymentRequest struct {
+	DocumentNumber string  `json:"document_number"`
+	CardType       string  `json:"card_type"`
+	Date           string  `json:"date"`
+	Amount         float64 `json:"amount"`
+	Currency       string  `json:"currency"`
+	Note           string  `json:"note"`
+}
+
+type LedgerStatement struct {
+	DocumentNumber          string               `json:"document_number"`
+	ResumeDate              string               `json:"resume_date"`
+	ClosingDate             string               `json:"closing_date"`
+	DueDate                 string               `json:"due_date"`
+	TotalARS                float64              `json:"total_ars"`
+	FormattedTotalARS       string               `json:"formatted_total_ars"`
+	MinimumPayment          float64              `json:"minimum_payment"`
+	FormattedMinimumPayment string               `json:"formatted_minimum_payment"`
+	PaidARS                 float64              `json:"paid_ars"`
+	FormattedPaidARS        string               `json:"formatted_paid_ars"`
+	OutstandingARS          float64              `json:"outstanding_ars"`
+	FormattedOutstandingARS string               `json:"formatted_outstanding_ars"`
+	TotalUSD                float64              `json:"total_usd"`
+	PaidUSD                 float64              `json:"paid_usd"`
+	OutstandingUSD          float64              `json:"outstanding_usd"`
+	Status                  string               `json:"status"`        // paid | minimum | partial | unpaid, on the pesos balance
+	InterestRisk            string               `json:"interest_risk"` // none | pending | interest | late | unknown
+	Payments                []models.CardPayment `json:"payments"`
+}
+
+type CardLedger struct {
+	CardType                string            `json:"card_type"`
+	OutstandingARS          float64           `json:"outstanding_ars"`
+	FormattedOutstandingARS string            `json:"formatted_outstanding_ars"`
+	OutstandingUSD          float64           `json:"outstanding_usd"`
+	FormattedOutstandingUSD string            `json:"formatted_outstanding_usd"`
+	InterestRisk            string            `json:"interest_risk"`
+	Statements              []LedgerStatement `json:"statements"`
+}
+
+/*
+GetCardPayments lists the recorded card payments, newest first
+- card_type / document_number: optional filters
+*/
+func (ec *CardsController) GetCardPayments(c *gin.Context) {
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Model(&models.CardPayment{})
+	if cardType := c.Query("card_type"); cardType != "" {
+		query = query.Where("card_type = ?", cardType)
+	}
+	if documentNumber := c.Query("document_number"); documentNumber != "" {
+		query = query.Where("document_number = ?", documentNumber)
+	}
+
+	payments := []models.CardPayment{}
+	if err := query.Order("date DESC, id DESC").Find(&payments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, payments)
+}
+
+/*
+CreateCardPayment records a payment made outside the sheet
+- body: {"document_number", "card_type", "date" (YYYY-MM-DD), "amount", "currency" (ARS | USD), "note"}
+Without document_number the payment goes to the last statement of card_type closed before the date.
+*/
+func (ec *CardsController) CreateCardPayment(c *gin.Context) {
+
+	var request CardPaymentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", request.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date, expected YYYY-MM-DD"})
+		return
+	}
+	if request.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+	currency := strings.ToUpper(request.Currency)
+	if currency == "" {
+		currency = "ARS"
+	}
+	if currency != "ARS" && currency != "USD" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid currency, expected ARS or USD"})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var resumes []models.Resume
+	query := db.Order("resume_date DESC")
+	if request.DocumentNumber != "" {
+		query = query.Where("document_number = ?", request.DocumentNumber)
+	} else if request.CardType != "" {
+		query = query.Where("card_type = ?", request.CardType)
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "document_number or card_type is required"})
+		return
+	}
+	if err := query.Find(&resumes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var resume *models.Resume
+	for i := range resumes {
+		closing, ok := services.StatementClosingDate(resumes[i])
+		if request.DocumentNumber != "" || (ok && !closing.After(date)) {
+			resume = &resumes[i]
+			break
+		}
+	}
+	if resume == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "statement not found"})
+		return
+	}
+
+	payment := models.CardPayment{
+		DocumentNumber: resume.DocumentNumber,
+		CardType:       resume.CardType,
+		Date:           date.Format("2006-01-02"),
+		Amount:         request.Amount,
+		Currency:       currency,
+		Source:         services.CardPaymentSourceManual,
+		Note:           request.Note,
+	}
+	if err := db.Create(&payment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, payment)
+}
+
+// DeleteCardPayment removes a payment, matched sheet payments are linked again by the next match
+func (ec *CardsController) DeleteCardPayment(c *gin.Context) {
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := db.Delete(&models.CardPayment{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "payment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": id})
+}
+
+// MatchCardPayments links the card payments of the sheet (CARD_PAYMENT_TYPE) to the statements they pay
+func (ec *CardsController) MatchCardPayments(c *gin.Context) {
+
+	transactionsDB, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cardsDB, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.MatchCardPayments(transactionsDB, cardsDB, services.CardPaymentType(ec.CurrentTenant(c)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+/*
+GetCardLedger shows, per card, what every statement owed, what was paid and what is still owed
+- card_type: optional filter
+A statement paid below its total after the due date carries interest ("interest"), below the
+minimum payment it is also late ("late"); statements not due yet and not fully paid are "pending"
+("unknown" when the parser did not read the due date).
+The balance of a card is the outstanding amount of its last statement, which already
+includes what was left unpaid from the previous ones.
+*/
+func (ec *CardsController) GetCardLedger(c *gin.Context) {
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Model(&models.Resume{})
+	if cardType := c.Query("card_type"); cardType != "" {
+		query = query.Where("card_type = ?", cardType)
+	}
+
+	var resumes []models.Resume
+	if err := query.Order("card_type ASC, resume_date ASC").Find(&resumes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var payments []models.CardPayment
+	if err := db.Order("date ASC, id ASC").Find(&payments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	byResume := make(map[string][]models.CardPayment)
+	for _, payment := range payments {
+		byResume[payment.DocumentNumber] = append(byResume[payment.DocumentNumber], payment)
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	ledgers := []CardLedger{}
+	for _, resume := range resumes {
+
+		statement := LedgerStatement{
+			DocumentNumber: resume.DocumentNumber,
+			ResumeDate:     resume.ResumeDate,
+			ClosingDate:    resume.ClosingDate,
+			DueDate:        resume.DueDate,
+			TotalARS:       resume.TotalARS,
+			MinimumPayment: resume.MinimumPayment,
+			TotalUSD:       resume.TotalUSD,
+			Payments:       []models.CardPayment{},
+		}
+		for _, payment := range byResume[resume.DocumentNumber] {
+			if payment.Currency == "USD" {
+				statement.PaidUSD += payment.Amount
+			} else {
+				statement.PaidARS += payment.Amount
+			}
+			statement.Payments = append(statement.Payments, payment)
+		}
+		statement.OutstandingARS = max(resume.TotalARS-statement.PaidARS, 0)
+		statement.OutstandingUSD = max(resume.TotalUSD-statement.PaidUSD, 0)
+		statement.Status, statement.InterestRisk = statementPaymentStatus(statement, today)
+		statement.FormattedTotalARS = ec.FormatAmount(statement.TotalARS)
+		statement.FormattedMinimumPayment = ec.FormatAmount(statement.MinimumPayment)
+		statement.FormattedPaidARS = ec.FormatAmount(statement.PaidARS)
+		statement.FormattedOutstandingARS = ec.FormatAmount(statement.OutstandingARS)
+
+		if len(ledgers) == 0 || ledgers[len(ledgers)-1].CardType != resume.CardType {
+			ledgers = append(ledgers, CardLedger{CardType: resume.CardType})
+		}
+		ledger := &ledgers[len(ledgers)-1]
+		ledger.Statements = append(ledger.Statements, statement)
+		ledger.OutstandingARS = statement.OutstandingARS
+		ledger.OutstandingUSD = statement.OutstandingUSD
+		ledger.InterestRisk = statement.InterestRisk
+	}
+
+	for i := range ledgers {
+		ledgers[i].FormattedOutstandingARS = ec.FormatAmount(ledgers[i].OutstandingARS)
+		ledgers[i].FormattedOutstandingUSD = ec.FormatAmount(ledgers[i].OutstandingUSD)
+	}
+
+	c.JSON(http.StatusOK, ledgers)
+}
+
+// statementPaymentStatus classifies how a statement was paid and the interest it may carry, see GetCardLedger
+func statementPaymentStatus(statement LedgerStatement, today string) (string, string) {
+
+	const tolerance = 0.5
+
+	status := "unpaid"
+	switch {
+	case statement.OutstandingARS <= tolerance:
+		status = "paid"
+	case statement.MinimumPayment > 0 && statement.PaidARS+tolerance >= statement.MinimumPayment:
+		status = "minimum"
+	case statement.PaidARS > 0:
+		status = "partial"
+	}
+
+	switch {
+	case status == "paid":
+		return status, "none"
+	case statement.DueDate == "":
+		return status, "unknown"
+	case statement.DueDate >= today:
+		return status, "pending"
+	case status == "minimum" || statement.MinimumPayment == 0 && statement.PaidARS > 0:
+		return status, "interest"
+	default:
+		return status, "late"
+	}
+}
+
+// GetReconciliation lists the sheet card payments linked to statements and the unmatched items on both sides
+func (ec *CardsController) GetReconciliation(c *gin.Context) {
+
+	transactionsDB, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cardsDB, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.GetReconciliation(transactionsDB, cardsDB, services.CardPaymentType(ec.CurrentTenant(c)), time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+/*
+ReconcileCardPayments matches the sheet card payments against the statements and marks
+the linked rows as transfers, so expense summaries stop counting them next to the statements
+*/
+func (ec *CardsController) ReconcileCardPayments(c *gin.Context) {
+
+	transactionsDB, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cardsDB, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.ReconcileCardPayments(transactionsDB, cardsDB, services.CardPaymentType(ec.CurrentTenant(c)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
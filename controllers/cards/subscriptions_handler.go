@@ -0,0 +1,120 @@
+package cards
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DetectedSubscription struct {
+	models.Subscription
+	FormattedLastAmount string `json:"formatted_last_amount"`
+}
+
+/*
+GetDetectedSubscriptions lists the recurring charges found on the statements
+- status: active | cancelled | all (default)
+*/
+func (ec *CardsController) GetDetectedSubscriptions(c *gin.Context) {
+
+	status := c.DefaultQuery("status", "all")
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := services.ScopeHolders(db.Model(&models.Subscription{}), ec.AllowedHolders(c), "holder").
+		Preload("Prices", func(tx *gorm.DB) *gorm.DB { return tx.Order("month ASC") })
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+
+	var subscriptions []models.Subscription
+	if err := query.Order("status ASC, last_amount DESC").Find(&subscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]DetectedSubscription, len(subscriptions))
+	for i, subscription := range subscriptions {
+		response[i] = DetectedSubscription{
+			Subscription:        subscription,
+			FormattedLastAmount: ec.FormatAmount(subscription.LastAmount),
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DetectSubscriptions runs the recurring charges detection over every stored statement
+func (ec *CardsController) DetectSubscriptions(c *gin.Context) {
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.DetectSubscriptions(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+/*
+UpdateSubscriptionStatus marks a detected subscription as cancelled or active again
+- body: {"status": "cancelled" | "active"}
+Charges found after a subscription was cancelled raise a charge_after_cancel alert.
+*/
+func (ec *CardsController) UpdateSubscriptionStatus(c *gin.Context) {
+
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || (body.Status != "active" && body.Status != "cancelled") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status, expected active or cancelled"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var subscription models.Subscription
+	if err := db.First(&subscription, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	}
+
+	subscription.Status = body.Status
+	subscription.CancelledAt = nil
+	if body.Status == "cancelled" {
+		now := time.Now()
+		subscription.CancelledAt = &now
+	}
+
+	if err := db.Save(&subscription).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
@@ -0,0 +1,154 @@
+package cards
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"finance-backend/config"
+	"finance-backend/services"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxStatementSize = 20 << 20 // statements are a few hundred KB, the form fields fit in the same budget
+
+var uploadCardType = regexp.MustCompile(`^[a-z0-9][a-z0-9 _-]{0,39}$`)
+
+/*
+UploadResume stores an uploaded statement PDF and imports it like SyncResumes does
+- multipart form: file (PDF), card_type (visa, mastercard, ...), period ("MM-YYYY") and issuer (bbva by default)
+- replace: "true" to replace the statement already stored for the card and period with this file
+Requests over 20 MB are rejected. The original file is stored once it is parsed, it is kept under CARD_UPLOADS_PATH/<card_type>/<period>-<file hash>.pdf ("uploads" by default),
+so every uploaded version of a statement is preserved. Other households keep theirs under CARD_UPLOADS_PATH/tenants/<slug>.
+*/
+func (ec *CardsController) UploadResume(c *gin.Context) {
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxStatementSize)
+	if _, err := c.MultipartForm(); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("the statement is too large, the limit is %d MB", maxStatementSize>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cardType := strings.ToLower(strings.TrimSpace(c.PostForm("card_type")))
+	period := strings.TrimSpace(c.PostForm("period"))
+	issuer := strings.ToLower(strings.TrimSpace(c.DefaultPostForm("issuer", "bbva")))
+
+	if !uploadCardType.MatchString(cardType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid card_type"})
+		return
+	}
+	if _, err := parseMonthYear(period); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period, expected MM-YYYY"})
+		return
+	}
+
+	tenant := ec.CurrentTenant(c)
+	parser, err := services.NewStatementParser(tenant, issuer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxStatementSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if http.DetectContentType(content) != "application/pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is not a PDF"})
+		return
+	}
+
+	// ---------- Original file ----------
+
+	directory := filepath.Join(services.TenantPath(tenant, config.String("CARD_UPLOADS_PATH")), cardType)
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error creating uploads directory: %v", err)})
+		return
+	}
+	sum := sha256.Sum256(content)
+	filePath := filepath.Join(directory, period+"-"+hex.EncodeToString(sum[:])[:12]+".pdf")
+
+	// The parsers read files, the upload is written to a temporary one until it is parsed
+	temporary, err := os.CreateTemp(directory, ".upload-*.pdf")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error storing the statement: %v", err)})
+		return
+	}
+	defer os.Remove(temporary.Name())
+	_, err = temporary.Write(content)
+	if closeErr := temporary.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error storing the statement: %v", err)})
+		return
+	}
+
+	// ---------- Same pipeline as SyncResumes ----------
+
+	parsed, err := readResumeFile(parser, resumePaths{
+		Issuer:   issuer,
+		CardLogo: cardType,
+		FilePath: temporary.Name(),
+		FileName: period,
+	})
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := os.Rename(temporary.Name(), filePath); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error storing the statement: %v", err)})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resumes := ec.buildResumes([]ResumesData{parsed})
+	response, created := ec.storeResumes(c, db, resumes, c.PostForm("replace") == "true")
+
+	result := gin.H{"status": response[0], "resume": resumes[0], "file": filePath}
+	if created > 0 {
+		if detection, err := services.DetectSubscriptions(db); err != nil {
+			result["subscriptions_detection_error"] = err.Error()
+		} else {
+			result["subscriptions_detection"] = detection
+		}
+	}
+
+	status := http.StatusOK
+	if created > 0 {
+		status = http.StatusCreated
+	}
+	c.JSON(status, result)
+}
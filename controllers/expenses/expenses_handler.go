@@ -1,10 +1,10 @@
 package expenses
 
 import (
-	"finance-backend/config"
 	"finance-backend/models"
 	"finance-backend/services"
 	"fmt"
+	"log"
 	"net/http"
 
 	"strconv"
@@ -66,7 +66,7 @@ func (ec *ExpenseController) GetRecentExpenses(c *gin.Context) {
 
 	dateFilter := fmt.Sprintf("%04d-%02d", year, month)
 
-	db, err := ec.GetDatabaseInstance("TRANSACTION_DB")
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -127,7 +127,7 @@ func (ec *ExpenseController) GetExpenses(c *gin.Context) {
 
 	dateFilter := fmt.Sprintf("%04d-%02d", year, month)
 
-	db, err := ec.GetDatabaseInstance("TRANSACTION_DB")
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -187,6 +187,7 @@ func (ec *ExpenseController) GetExpensesSummary(c *gin.Context) {
 		Total          float64       `json:"total"`
 		FormattedTotal string        `json:"formatted_total"`
 		Period         string        `json:"period"`
+		AdjustedTo     string        `json:"adjusted_to,omitempty"`
 		TypesSummary   []TypeSummary `json:"types_summary"`
 	}
 
@@ -223,7 +224,14 @@ func (ec *ExpenseController) GetExpensesSummary(c *gin.Context) {
 	dateFilter := fmt.Sprintf("%04d-%02d", year, month)
 	period := fmt.Sprintf("%02d-%04d", month, year)
 
-	db, err := ec.GetDatabaseInstance("TRANSACTION_DB")
+	// Optional inflation adjustment: ?adjust=real&base=YYYY-MM
+	adjuster, err := ec.GetInflationAdjuster(c, c.Query("adjust"), c.Query("base"))
+	if err != nil {
+		c.JSON(ec.InflationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -235,11 +243,17 @@ func (ec *ExpenseController) GetExpensesSummary(c *gin.Context) {
 		Total float64
 	}
 
-	if err := db.Model(&models.Expenses{}).
+	query := db.Model(&models.Expenses{}).
 		Select("type, sum(amount) as total").
 		Where("strftime('%Y-%m', date) = ?", dateFilter).
-		Where("type NOT IN ?", exclude).
-		Group("type").Order("total desc").
+		Where("type NOT IN ?", exclude)
+
+	// Card payments reconciled with their statement are transfers, not spending (?include_transfers=true to count them)
+	if c.Query("include_transfers") != "true" {
+		query = query.Where("transfer = ?", false)
+	}
+
+	if err := query.Group("type").Order("total desc").
 		Find(&typeSummaries).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -250,6 +264,11 @@ func (ec *ExpenseController) GetExpensesSummary(c *gin.Context) {
 	formattedTypeSummaries := make([]TypeSummary, len(typeSummaries))
 
 	for i, ts := range typeSummaries {
+		ts.Total, err = adjuster.Adjust(ts.Total, dateFilter)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		total += ts.Total
 		formattedTypeSummaries[i] = TypeSummary{
 			Type:           ts.Type,
@@ -265,6 +284,9 @@ func (ec *ExpenseController) GetExpensesSummary(c *gin.Context) {
 		Period:         period,
 		TypesSummary:   formattedTypeSummaries,
 	}
+	if adjuster != nil {
+		response.AdjustedTo = adjuster.BaseMonth
+	}
 
 	c.JSON(http.StatusOK, gin.H{"ExpensesSummary": response})
 }
@@ -289,8 +311,11 @@ func (ec *ExpenseController) SyncCurrentMonthExpenses(c *gin.Context) {
 
 	datePattern2 := fmt.Sprintf("%%/%s/%s%%", new_month_format, year)
 
-	spreadsheetID := config.GetEnv("GS_SPREADSHEET_ID")
-	sheetName := config.GetEnv("GS_SHEET_ID")
+	spreadsheetID, sheetName, err := services.TenantSheet(ec.CurrentTenant(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	sheetRange := "GastosMesActual!A:Z" // Lee todas las columnas
 
 	syncParameters := SyncExpenseData{
@@ -302,7 +327,7 @@ func (ec *ExpenseController) SyncCurrentMonthExpenses(c *gin.Context) {
 		SheetRange:     sheetRange,
 	}
 
-	expensesInserted, expensesDeleted, err := SyncData(syncParameters)
+	expensesInserted, expensesDeleted, err := SyncData(c, syncParameters)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -322,8 +347,11 @@ func (ec *ExpenseController) SyncCurrentMonthExpenses(c *gin.Context) {
 
 func (ec *ExpenseController) SyncExpensesHistorical(c *gin.Context) {
 
-	spreadsheetID := config.GetEnv("GS_SPREADSHEET_ID")
-	sheetName := config.GetEnv("GS_SHEET_ID")
+	spreadsheetID, sheetName, err := services.TenantSheet(ec.CurrentTenant(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	sheetRange := "Gastos!A:Z" // Lee todas las columnas
 
 	syncParameters := SyncExpenseData{
@@ -333,7 +361,7 @@ func (ec *ExpenseController) SyncExpensesHistorical(c *gin.Context) {
 		SheetRange:     sheetRange,
 	}
 
-	expensesInserted, expensesDeleted, err := SyncData(syncParameters)
+	expensesInserted, expensesDeleted, err := SyncData(c, syncParameters)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -352,7 +380,7 @@ func (ec *ExpenseController) SyncExpensesHistorical(c *gin.Context) {
 
 }
 
-func SyncData(parameters SyncExpenseData) (expensesInserted []models.Expenses, expensesDeleted []models.Expenses, Error error) {
+func SyncData(c *gin.Context, parameters SyncExpenseData) (expensesInserted []models.Expenses, expensesDeleted []models.Expenses, Error error) {
 
 	ec := NewExpenseController()
 
@@ -377,7 +405,7 @@ func SyncData(parameters SyncExpenseData) (expensesInserted []models.Expenses, e
 		return nil, nil, fmt.Errorf("error trying to parse sheet data to map at ExpenseSheetDataToMap )")
 	}
 
-	db, err := ec.GetDatabaseInstance("TRANSACTION_DB")
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
 	if err != nil {
 		return nil, nil, fmt.Errorf("error trying to connect to database at getDB()")
 	}
@@ -419,6 +447,11 @@ func SyncData(parameters SyncExpenseData) (expensesInserted []models.Expenses, e
 	//Handle records deletions, it will delete by primary key ID
 	if len(expensesToDelete) > 0 {
 		db.Delete(&expensesToDelete)
+
+		// Receipts of the deleted rows go with them
+		if _, err := ec.PruneAttachments(c, services.RecordSourceExpense); err != nil {
+			log.Printf("error removing attachments of deleted rows: %v", err)
+		}
 	}
 
 	return expensesToInsert, expensesToDelete, nil
@@ -0,0 +1,393 @@
+package holders
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	transactions "finance-backend/controllers/base"
+)
+
+type HoldersController struct {
+	*transactions.BaseController // Embed base to share base methods
+}
+
+func NewHoldersController() *HoldersController {
+	return &HoldersController{
+		BaseController: &transactions.BaseController{},
+	}
+}
+
+type CategoryTotal struct {
+	Category       string  `json:"category"`
+	Total          float64 `json:"total"`
+	FormattedTotal string  `json:"formatted_total"`
+	Items          int     `json:"items"`
+	Share          float64 `json:"share"` // percentage of the holder spending
+}
+
+type MonthTotal struct {
+	Month          string  `json:"month"` // formato: "2025-07"
+	Total          float64 `json:"total"`
+	FormattedTotal string  `json:"formatted_total"`
+}
+
+type HolderSummary struct {
+	Holder         string          `json:"holder"`
+	From           string          `json:"from"`
+	To             string          `json:"to"`
+	Total          float64         `json:"total"`
+	FormattedTotal string          `json:"formatted_total"`
+	Categories     []CategoryTotal `json:"categories"`
+	Months         []MonthTotal    `json:"months"`
+}
+
+/*
+GetHolderSummary totals the card spending of a holder across every card, per category and month
+- name: holder as printed on the statements (case insensitive)
+- from / to: "MM-YYYY" statement months, the last 12 months by default
+Categories come from the "category" merchant mappings. Split line items count with the holder share,
+including the ones on other holders cards.
+*/
+func (ec *HoldersController) GetHolderSummary(c *gin.Context) {
+
+	name := strings.TrimSpace(c.Param("name"))
+
+	from, to, err := monthRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var holders int64
+	if err := db.Model(&models.Holder{}).Where("UPPER(TRIM(holder)) = ?", strings.ToUpper(name)).Count(&holders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if holders == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "holder not found"})
+		return
+	}
+
+	items, err := services.HolderEffectiveItems(db, from, to, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	categorizer, err := services.NewHolderCategorizer(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	categories := make(map[string]*CategoryTotal)
+	months := make(map[string]float64)
+	total := 0.0
+	for _, item := range items {
+		category := categorizer.Category(item.Description)
+		if categories[category] == nil {
+			categories[category] = &CategoryTotal{Category: category}
+		}
+		categories[category].Total += item.Amount
+		categories[category].Items++
+		months[item.ResumeDate[:7]] += item.Amount
+		total += item.Amount
+	}
+
+	summary := HolderSummary{
+		Holder:         name,
+		From:           from.Format("01-2006"),
+		To:             to.Format("01-2006"),
+		Total:          total,
+		FormattedTotal: ec.FormatAmount(total),
+		Categories:     []CategoryTotal{},
+		Months:         []MonthTotal{},
+	}
+	if len(items) > 0 {
+		summary.Holder = items[0].Holder
+	}
+
+	for _, category := range categories {
+		category.FormattedTotal = ec.FormatAmount(category.Total)
+		if total != 0 {
+			category.Share = category.Total / total * 100
+		}
+		summary.Categories = append(summary.Categories, *category)
+	}
+	sort.Slice(summary.Categories, func(i, j int) bool {
+		return summary.Categories[i].Total > summary.Categories[j].Total
+	})
+
+	for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+		key := month.Format("2006-01")
+		summary.Months = append(summary.Months, MonthTotal{
+			Month:          key,
+			Total:          months[key],
+			FormattedTotal: ec.FormatAmount(months[key]),
+		})
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+/*
+GetSettlement splits the shared card spending of a period with the split rules and
+lists the transfers that settle it between the holders
+- from / to: "MM-YYYY" statement months, the last 12 months by default
+*/
+func (ec *HoldersController) GetSettlement(c *gin.Context) {
+
+	from, to, err := monthRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	settlement, err := services.BuildSettlement(db, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	members := make([]gin.H, len(settlement.Members))
+	for i, member := range settlement.Members {
+		members[i] = gin.H{
+			"name":              member.Name,
+			"paid":              member.Paid,
+			"formatted_paid":    ec.FormatAmount(member.Paid),
+			"owed":              member.Owed,
+			"formatted_owed":    ec.FormatAmount(member.Owed),
+			"balance":           member.Balance,
+			"formatted_balance": ec.FormatAmount(member.Balance),
+		}
+	}
+
+	transfers := make([]gin.H, len(settlement.Transfers))
+	for i, transfer := range settlement.Transfers {
+		transfers[i] = gin.H{
+			"from":             transfer.From,
+			"to":               transfer.To,
+			"amount":           transfer.Amount,
+			"formatted_amount": ec.FormatAmount(transfer.Amount),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":      from.Format("01-2006"),
+		"to":        to.Format("01-2006"),
+		"members":   members,
+		"transfers": transfers,
+	})
+}
+
+// GetSplitRules lists the split rules with their shares
+func (ec *HoldersController) GetSplitRules(c *gin.Context) {
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules := []models.SplitRule{}
+	if err := db.Preload("Shares").Order("category ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+/*
+CreateSplitRule stores the split of a category
+- body: {"category", "shares": [{"member", "percentage"}]}, e.g. 50/50 on "Supermercado"
+*/
+func (ec *HoldersController) CreateSplitRule(c *gin.Context) {
+
+	var rule models.SplitRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.ID = 0
+
+	if err := services.ValidateSplitRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if conflict, err := categoryHasRule(db, rule); err != nil || conflict {
+		ec.ruleConflict(c, err)
+		return
+	}
+
+	if err := db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateSplitRule replaces the category and shares of an existing rule
+func (ec *HoldersController) UpdateSplitRule(c *gin.Context) {
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var rule models.SplitRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.ID = uint(id)
+
+	if err := services.ValidateSplitRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var existing models.SplitRule
+	if err := db.Where("id = ?", id).Limit(1).Find(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if existing.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "split rule not found"})
+		return
+	}
+	if conflict, err := categoryHasRule(db, rule); err != nil || conflict {
+		ec.ruleConflict(c, err)
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("rule_id = ?", id).Delete(&models.SplitShare{}).Error; err != nil {
+			return err
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&rule).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error updating split rule: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteSplitRule removes a rule, its category becomes personal spending again
+func (ec *HoldersController) DeleteSplitRule(c *gin.Context) {
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var deleted int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("rule_id = ?", id).Delete(&models.SplitShare{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.SplitRule{}, id)
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "split rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": id})
+}
+
+// categoryHasRule tells whether another rule already splits the category (case insensitive)
+func categoryHasRule(db *gorm.DB, rule models.SplitRule) (bool, error) {
+	var existing int64
+	err := db.Model(&models.SplitRule{}).
+		Where("LOWER(category) = LOWER(?) AND id <> ?", rule.Category, rule.ID).
+		Count(&existing).Error
+	return existing > 0, err
+}
+
+func (ec *HoldersController) ruleConflict(c *gin.Context, err error) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "the category already has a split rule"})
+}
+
+// monthRange reads the from/to "MM-YYYY" query params, the last 12 months by default
+func monthRange(c *gin.Context) (time.Time, time.Time, error) {
+
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse("01-2006", value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to, expected MM-YYYY")
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, -11, 0)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse("01-2006", value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from, expected MM-YYYY")
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from is after to")
+	}
+	return from, to, nil
+}
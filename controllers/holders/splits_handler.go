@@ -0,0 +1,154 @@
+package holders
+
+import (
+	"errors"
+	"finance-backend/models"
+	"finance-backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ExpenseSplitRequest struct {
+	Source         string                     `json:"source"` // expense | card
+	UUID           string                     `json:"uuid"`   // sheet expense
+	DocumentNumber string                     `json:"document_number"`
+	Holder         string                     `json:"holder"`
+	Position       int                        `json:"position"`
+	Method         string                     `json:"method"` // equal | percentage | fixed
+	Shares         []models.ExpenseSplitShare `json:"shares"`
+}
+
+/*
+GetExpenseSplits lists the split sheet expenses and card line items
+- source: expense | card (both by default)
+- member: only the splits that include the member
+*/
+func (ec *HoldersController) GetExpenseSplits(c *gin.Context) {
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Preload("Shares")
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if member := c.Query("member"); member != "" {
+		query = query.Where("id IN (?)", db.Model(&models.ExpenseSplitShare{}).
+			Select("split_id").
+			Where("UPPER(TRIM(member)) = UPPER(TRIM(?))", member))
+	}
+
+	splits := []models.ExpenseSplit{}
+	if err := query.Order("created_at DESC").Find(&splits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, splits)
+}
+
+/*
+SaveExpenseSplit splits a sheet expense or a card line item between members, replacing its previous split
+- body: {"source": "expense", "uuid"} or {"source": "card", "document_number", "holder", "position"}
+- method: equal (shares with member only) | percentage (member, percentage) | fixed (member, amount)
+*/
+func (ec *HoldersController) SaveExpenseSplit(c *gin.Context) {
+
+	var request ExpenseSplitRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	split := models.ExpenseSplit{
+		Source: request.Source,
+		Method: request.Method,
+		Shares: request.Shares,
+	}
+	switch request.Source {
+	case services.RecordSourceExpense:
+		split.RecordKey = request.UUID
+	case services.RecordSourceCard:
+		split.RecordKey = services.CardItemKey(request.DocumentNumber, request.Holder, request.Position)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid source, expected expense or card"})
+		return
+	}
+
+	transactionsDB, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cardsDB, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	split.Amount, err = services.SplitRecordAmount(transactionsDB, cardsDB, split.Source, split.RecordKey)
+	if errors.Is(err, services.ErrSplitRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.BuildSplitShares(&split); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	split, err = services.SaveExpenseSplit(cardsDB, split)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, split)
+}
+
+// DeleteExpenseSplit removes a split, the record counts fully for whoever paid it again
+func (ec *HoldersController) DeleteExpenseSplit(c *gin.Context) {
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var deleted int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("split_id = ?", id).Delete(&models.ExpenseSplitShare{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.ExpenseSplit{}, id)
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "split not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": id})
+}
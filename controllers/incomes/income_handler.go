@@ -1,10 +1,10 @@
 package incomes
 
 import (
-	"finance-backend/config"
 	"finance-backend/models"
 	"finance-backend/services"
 	"fmt"
+	"log"
 	"net/http"
 
 	"strconv"
@@ -83,7 +83,7 @@ func (ec *IncomeController) GetIncomes(c *gin.Context) {
 
 	datePattern2 := fmt.Sprintf("%%/%s/%s%%", new_month_format, year)
 
-	db, err := ec.GetDatabaseInstance("TRANSACTION_DB")
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -154,8 +154,11 @@ func (ec *IncomeController) SyncCurrentMonthIncomes(c *gin.Context) {
 
 	datePattern2 := fmt.Sprintf("%%/%s/%s%%", new_month_format, year)
 
-	spreadsheetID := config.GetEnv("GS_SPREADSHEET_ID")
-	sheetName := config.GetEnv("GS_SHEET_ID")
+	spreadsheetID, sheetName, err := services.TenantSheet(ec.CurrentTenant(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	sheetRange := "IncomeMesActual!A:Z" // Lee todas las columnas
 
 	syncParameters := SyncIncomeData{
@@ -167,7 +170,7 @@ func (ec *IncomeController) SyncCurrentMonthIncomes(c *gin.Context) {
 		SheetRange:     sheetRange,
 	}
 
-	incomesInserted, incomesDeleted, err := SyncData(syncParameters)
+	incomesInserted, incomesDeleted, err := SyncData(c, syncParameters)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -187,8 +190,11 @@ func (ec *IncomeController) SyncCurrentMonthIncomes(c *gin.Context) {
 
 func (ec *IncomeController) SyncIncomesHistorical(c *gin.Context) {
 
-	spreadsheetID := config.GetEnv("GS_SPREADSHEET_ID")
-	sheetName := config.GetEnv("GS_SHEET_ID")
+	spreadsheetID, sheetName, err := services.TenantSheet(ec.CurrentTenant(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	sheetRange := "Income!A:Z" // Lee todas las columnas
 
 	syncParameters := SyncIncomeData{
@@ -198,7 +204,7 @@ func (ec *IncomeController) SyncIncomesHistorical(c *gin.Context) {
 		SheetRange:     sheetRange,
 	}
 
-	incomesInserted, incomesDeleted, err := SyncData(syncParameters)
+	incomesInserted, incomesDeleted, err := SyncData(c, syncParameters)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -217,7 +223,7 @@ func (ec *IncomeController) SyncIncomesHistorical(c *gin.Context) {
 
 }
 
-func SyncData(parameters SyncIncomeData) (incomesInserted []models.Incomes, incomesDeleted []models.Incomes, Error error) {
+func SyncData(c *gin.Context, parameters SyncIncomeData) (incomesInserted []models.Incomes, incomesDeleted []models.Incomes, Error error) {
 
 	ec := NewIncomeController()
 
@@ -242,7 +248,7 @@ func SyncData(parameters SyncIncomeData) (incomesInserted []models.Incomes, inco
 		return nil, nil, fmt.Errorf("error trying to parse sheet data to map at ExpenseSheetDataToMap ): %w", err)
 	}
 
-	db, err := ec.GetDatabaseInstance("TRANSACTION_DB")
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
 	if err != nil {
 		return nil, nil, fmt.Errorf("error trying to connect to database at getDB()")
 	}
@@ -284,6 +290,11 @@ func SyncData(parameters SyncIncomeData) (incomesInserted []models.Incomes, inco
 	//Handle records deletions, it will delete by primary key ID
 	if len(incomesToDelete) > 0 {
 		db.Delete(&incomesToDelete)
+
+		// Receipts of the deleted rows go with them
+		if _, err := ec.PruneAttachments(c, services.RecordSourceIncome); err != nil {
+			log.Printf("error removing attachments of deleted rows: %v", err)
+		}
 	}
 
 	return incomesToInsert, incomesToDelete, nil
@@ -0,0 +1,311 @@
+package reports
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ComparisonItem struct {
+	Key               string   `json:"key"`
+	Current           float64  `json:"current"`
+	FormattedCurrent  string   `json:"formatted_current"`
+	Previous          float64  `json:"previous"`
+	FormattedPrevious string   `json:"formatted_previous"`
+	Delta             float64  `json:"delta"`
+	FormattedDelta    string   `json:"formatted_delta"`
+	DeltaPct          *float64 `json:"delta_pct"` // nil when there is nothing to compare against
+	Status            string   `json:"status"`    // new | disappeared | changed | unchanged
+}
+
+type ComparisonSection struct {
+	Current           float64          `json:"current"`
+	FormattedCurrent  string           `json:"formatted_current"`
+	Previous          float64          `json:"previous"`
+	FormattedPrevious string           `json:"formatted_previous"`
+	Delta             float64          `json:"delta"`
+	FormattedDelta    string           `json:"formatted_delta"`
+	DeltaPct          *float64         `json:"delta_pct"`
+	Items             []ComparisonItem `json:"items"`
+	TopMovers         []ComparisonItem `json:"top_movers"`
+	New               []string         `json:"new"`
+	Disappeared       []string         `json:"disappeared"`
+}
+
+type ComparisonResponse struct {
+	Compare        string            `json:"compare"`
+	Period         string            `json:"period"`
+	ComparedPeriod string            `json:"compared_period"`
+	Categories     ComparisonSection `json:"categories"`
+	Subscriptions  ComparisonSection `json:"subscriptions"`
+	Tags           []string          `json:"tags,omitempty"` // tag filter
+	TagTotals      []TagTotalItem    `json:"tag_totals"`     // tagged records of the month
+}
+
+/*
+GetComparison compares a month against the previous month (compare=mom) or
+the same month of the previous year (compare=yoy)
+- Categories are the sheet expense types
+- Subscriptions are card line items matched by the subscription mappings, grouped per card
+- top: how many movers to return (defaults to 5)
+- tag: comma separated tags, only the records with any of them are compared
+*/
+func (ec *ReportsController) GetComparison(c *gin.Context) {
+
+	year, err := strconv.Atoi(c.Query("year"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+		return
+	}
+
+	month, err := strconv.Atoi(c.Query("month"))
+	if err != nil || month < 1 || month > 12 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid month"})
+		return
+	}
+
+	compare := c.DefaultQuery("compare", "mom")
+	current := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+
+	var previous time.Time
+	switch compare {
+	case "mom":
+		previous = current.AddDate(0, -1, 0)
+	case "yoy":
+		previous = current.AddDate(-1, 0, 0)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid compare, expected mom or yoy"})
+		return
+	}
+
+	top, err := strconv.Atoi(c.DefaultQuery("top", "5"))
+	if err != nil || top < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid top"})
+		return
+	}
+
+	filter, err := ec.tagFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentCategories, err := ec.categoryTotals(c, current, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	previousCategories, err := ec.categoryTotals(c, previous, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentSubscriptions, err := ec.subscriptionTotals(c, current, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	previousSubscriptions, err := ec.subscriptionTotals(c, previous, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tagTotals, err := ec.tagTotals(c, current, current.AddDate(0, 1, -1), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := ComparisonResponse{
+		Compare:        compare,
+		Period:         current.Format("2006-01"),
+		ComparedPeriod: previous.Format("2006-01"),
+		Categories:     ec.compareTotals(currentCategories, previousCategories, top),
+		Subscriptions:  ec.compareTotals(currentSubscriptions, previousSubscriptions, top),
+		TagTotals:      tagTotals,
+	}
+	if filter != nil {
+		response.Tags = filter.Tags
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// categoryTotals sums sheet expenses per type for the month, only the tagged ones with a filter
+func (ec *ReportsController) categoryTotals(c *gin.Context, month time.Time, filter *services.TagFilter) (map[string]float64, error) {
+
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		return nil, err
+	}
+
+	cardsDB, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Type  string
+		Total float64
+	}
+
+	err = services.WithCardsDatabase(db, cardsDB, func(tx *gorm.DB) error {
+		return filterByTags(tx.Model(&models.Expenses{}), filter, services.RecordSourceExpense).
+			Select("type, sum(amount) as total").
+			Where("strftime('%Y-%m', date) = ?", month.Format("2006-01")).
+			Group("type").
+			Find(&rows).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching expense types at categoryTotals(): %w", err)
+	}
+
+	totals := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		totals[row.Type] = row.Total
+	}
+	return totals, nil
+}
+
+// subscriptionTotals sums the card line items of the month matched by the subscription mappings, keyed by "card - service"
+func (ec *ReportsController) subscriptionTotals(c *gin.Context, month time.Time, filter *services.TagFilter) (map[string]float64, error) {
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, err := services.NewMerchantMatcher(db, "subscription")
+	if err != nil {
+		return nil, err
+	}
+	if matcher.Len() == 0 {
+		return map[string]float64{}, nil
+	}
+
+	var rows []struct {
+		CardType    string
+		Description string
+		Amount      float64
+	}
+
+	query := db.Table("holder_expenses AS e").
+		Select("r.card_type, e.description, e.amount").
+		Joins("JOIN resumes r ON e.document_number = r.document_number").
+		Where("strftime('%Y-%m', r.resume_date) = ?", month.Format("2006-01"))
+	if err := filter.ScopeCardItems(query).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error fetching card expenses at subscriptionTotals(): %w", err)
+	}
+
+	totals := make(map[string]float64)
+	for _, row := range rows {
+		if mapping, ok := matcher.Match(row.Description); ok {
+			totals[row.CardType+" - "+mapping.Label] += row.Amount
+		}
+	}
+	return totals, nil
+}
+
+func (ec *ReportsController) compareTotals(current map[string]float64, previous map[string]float64, top int) ComparisonSection {
+
+	section := ComparisonSection{
+		Items:       []ComparisonItem{},
+		New:         []string{},
+		Disappeared: []string{},
+	}
+
+	keys := make(map[string]bool)
+	for key, amount := range current {
+		keys[key] = true
+		section.Current += amount
+	}
+	for key, amount := range previous {
+		keys[key] = true
+		section.Previous += amount
+	}
+
+	for key := range keys {
+		cur, inCurrent := current[key]
+		prev, inPrevious := previous[key]
+
+		item := ComparisonItem{
+			Key:      key,
+			Current:  cur,
+			Previous: prev,
+			Delta:    cur - prev,
+			DeltaPct: percentChange(cur, prev),
+		}
+
+		switch {
+		case inCurrent && !inPrevious:
+			item.Status = "new"
+			section.New = append(section.New, key)
+		case !inCurrent && inPrevious:
+			item.Status = "disappeared"
+			section.Disappeared = append(section.Disappeared, key)
+		case item.Delta == 0:
+			item.Status = "unchanged"
+		default:
+			item.Status = "changed"
+		}
+
+		item.FormattedCurrent = ec.FormatAmount(item.Current)
+		item.FormattedPrevious = ec.FormatAmount(item.Previous)
+		item.FormattedDelta = ec.FormatAmount(item.Delta)
+		section.Items = append(section.Items, item)
+	}
+
+	// Items by current amount, movers by absolute change
+	sort.Slice(section.Items, func(i, j int) bool {
+		if section.Items[i].Current != section.Items[j].Current {
+			return section.Items[i].Current > section.Items[j].Current
+		}
+		return section.Items[i].Key < section.Items[j].Key
+	})
+
+	movers := make([]ComparisonItem, 0, len(section.Items))
+	for _, item := range section.Items {
+		if item.Delta != 0 {
+			movers = append(movers, item)
+		}
+	}
+	sort.SliceStable(movers, func(i, j int) bool {
+		return math.Abs(movers[i].Delta) > math.Abs(movers[j].Delta)
+	})
+	if len(movers) > top {
+		movers = movers[:top]
+	}
+	section.TopMovers = movers
+
+	sort.Strings(section.New)
+	sort.Strings(section.Disappeared)
+
+	section.Delta = section.Current - section.Previous
+	section.DeltaPct = percentChange(section.Current, section.Previous)
+	section.FormattedCurrent = ec.FormatAmount(section.Current)
+	section.FormattedPrevious = ec.FormatAmount(section.Previous)
+	section.FormattedDelta = ec.FormatAmount(section.Delta)
+
+	return section
+}
+
+func percentChange(current float64, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	pct := math.Round((current-previous)/math.Abs(previous)*10000) / 100
+	return &pct
+}
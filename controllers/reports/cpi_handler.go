@@ -0,0 +1,72 @@
+package reports
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// GetCPIIndexes lists the loaded CPI table ordered by month
+func (ec *ReportsController) GetCPIIndexes(c *gin.Context) {
+
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var indexes []models.CPIIndex
+	if err := db.Order("period ASC").Find(&indexes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, indexes)
+}
+
+/*
+ImportCPIIndexes loads a "period,value" CSV into the CPI table
+- The CSV can be sent as a multipart "file" field or as the raw request body
+- Existing months are overwritten, so re-importing a revised series is safe
+*/
+func (ec *ReportsController) ImportCPIIndexes(c *gin.Context) {
+
+	var reader io.Reader = c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "missing file field"})
+			return
+		}
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer opened.Close()
+		reader = opened
+	}
+
+	indexes, err := services.ParseCPICSV(reader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&indexes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"imported_rows": len(indexes), "from": indexes[0].Period, "to": indexes[len(indexes)-1].Period})
+}
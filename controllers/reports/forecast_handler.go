@@ -0,0 +1,321 @@
+package reports
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CategoryForecast struct {
+	Type               string  `json:"type"`
+	MonthToDate        float64 `json:"month_to_date"`
+	FormattedToDate    string  `json:"formatted_month_to_date"`
+	PaceProjection     float64 `json:"pace_projection"`
+	SeasonalAverage    float64 `json:"seasonal_average"`
+	HistoricalNorm     float64 `json:"historical_norm"`
+	Projected          float64 `json:"projected"`
+	FormattedProjected string  `json:"formatted_projected"`
+	Low                float64 `json:"low"`
+	High               float64 `json:"high"`
+	FormattedRange     string  `json:"formatted_range"`
+	ExceedsNorm        bool    `json:"exceeds_norm"`
+	KnownFromStatement bool    `json:"known_from_statement"` // card payment category, projected from the statement total
+}
+
+type ForecastResponse struct {
+	Period             string             `json:"period"`
+	AsOf               string             `json:"as_of"`
+	DaysElapsed        int                `json:"days_elapsed"`
+	DaysInMonth        int                `json:"days_in_month"`
+	HistoryMonths      int                `json:"history_months"`
+	CardStatementARS   float64            `json:"card_statement_ars"`
+	FormattedCardARS   string             `json:"formatted_card_statement_ars"`
+	MonthToDate        float64            `json:"month_to_date"`
+	Projected          float64            `json:"projected"`
+	FormattedProjected string             `json:"formatted_projected"`
+	Low                float64            `json:"low"`
+	High               float64            `json:"high"`
+	FormattedRange     string             `json:"formatted_range"`
+	CategoriesOverNorm []string           `json:"categories_over_norm"`
+	Categories         []CategoryForecast `json:"categories"`
+	Tags               []string           `json:"tags,omitempty"` // tag filter
+	TagTotals          []TagTotalItem     `json:"tag_totals"`     // tagged records of the month
+}
+
+/*
+GetForecast projects month-end spending per expense type
+- year / month: month to forecast (defaults to the current one)
+- history: months used as the historical norm (defaults to 6)
+The projection blends the current pace with the seasonal average, giving more
+weight to the pace as the month advances. The range is one standard deviation
+of the historical months, narrowing as fewer days remain. The card payment type
+(CARD_PAYMENT_TYPE, "Tarjeta" by default) is projected from the card statements
+of the month since their amount is already known.
+- tag: comma separated tags, only the expenses with any of them are projected
+(statement totals are not split by tag, so they are left out)
+*/
+func (ec *ReportsController) GetForecast(c *gin.Context) {
+
+	now := time.Now()
+	year, month := now.Year(), int(now.Month())
+
+	var err error
+	if yearStr := c.Query("year"); yearStr != "" {
+		if year, err = strconv.Atoi(yearStr); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+			return
+		}
+	}
+	if monthStr := c.Query("month"); monthStr != "" {
+		if month, err = strconv.Atoi(monthStr); err != nil || month < 1 || month > 12 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid month"})
+			return
+		}
+	}
+
+	historyMonths, err := strconv.Atoi(c.DefaultQuery("history", "6"))
+	if err != nil || historyMonths < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid history"})
+		return
+	}
+
+	monthStart := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	monthEnd := monthStart.AddDate(0, 1, -1)
+	daysInMonth := monthEnd.Day()
+
+	// Past months are complete, future months have no elapsed days
+	asOf := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if asOf.After(monthEnd) {
+		asOf = monthEnd
+	}
+	daysElapsed := 0
+	if !asOf.Before(monthStart) {
+		daysElapsed = asOf.Day()
+	}
+	progress := float64(daysElapsed) / float64(daysInMonth)
+
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cardsDB, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter, err := ec.tagFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// ---------- Historical monthly totals per type ----------
+
+	var monthlyRows []struct {
+		Period string
+		Type   string
+		Total  float64
+	}
+
+	var toDateRows []struct {
+		Type  string
+		Total float64
+	}
+
+	err = services.WithCardsDatabase(db, cardsDB, func(tx *gorm.DB) error {
+		if err := filterByTags(tx.Model(&models.Expenses{}), filter, services.RecordSourceExpense).
+			Select("strftime('%Y-%m', date) as period, type, sum(amount) as total").
+			Where("strftime('%Y-%m', date) < ?", monthStart.Format("2006-01")).
+			Group("period, type").
+			Find(&monthlyRows).Error; err != nil {
+			return err
+		}
+
+		// ---------- Month to date ----------
+
+		return filterByTags(tx.Model(&models.Expenses{}), filter, services.RecordSourceExpense).
+			Select("type, sum(amount) as total").
+			Where("strftime('%Y-%m-%d', date) BETWEEN ? AND ?", monthStart.Format("2006-01-02"), asOf.Format("2006-01-02")).
+			Group("type").
+			Find(&toDateRows).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	history := make(map[string]map[string]float64) // type -> period -> total
+	for _, row := range monthlyRows {
+		if history[row.Type] == nil {
+			history[row.Type] = make(map[string]float64)
+		}
+		history[row.Type][row.Period] = row.Total
+	}
+
+	toDate := make(map[string]float64, len(toDateRows))
+	for _, row := range toDateRows {
+		toDate[row.Type] = row.Total
+	}
+
+	// ---------- Known card statement ----------
+
+	var statement []struct {
+		TotalArs float64
+	}
+
+	if err := cardsDB.Model(&models.Resume{}).
+		Select("sum(total_ars) as total_ars").
+		Where("strftime('%Y-%m', resume_date) = ?", monthStart.Format("2006-01")).
+		Find(&statement).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cardStatement := 0.0
+	if len(statement) > 0 && filter == nil {
+		cardStatement = statement[0].TotalArs
+	}
+
+	cardPaymentType := services.CardPaymentType(ec.CurrentTenant(c))
+
+	tagTotals, err := ec.tagTotals(c, monthStart, monthEnd, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// ---------- Projection ----------
+
+	types := make(map[string]bool)
+	for t := range history {
+		types[t] = true
+	}
+	for t := range toDate {
+		types[t] = true
+	}
+
+	response := ForecastResponse{
+		Period:             monthStart.Format("2006-01"),
+		AsOf:               asOf.Format("2006-01-02"),
+		DaysElapsed:        daysElapsed,
+		DaysInMonth:        daysInMonth,
+		HistoryMonths:      historyMonths,
+		CardStatementARS:   cardStatement,
+		FormattedCardARS:   ec.FormatAmount(cardStatement),
+		CategoriesOverNorm: []string{},
+		Categories:         []CategoryForecast{},
+		TagTotals:          tagTotals,
+	}
+	if filter != nil {
+		response.Tags = filter.Tags
+	}
+
+	for t := range types {
+
+		// Last N complete months, missing months count as zero spending
+		var recent []float64
+		for i := 1; i <= historyMonths; i++ {
+			recent = append(recent, history[t][monthStart.AddDate(0, -i, 0).Format("2006-01")])
+		}
+		norm, deviation := meanAndDeviation(recent)
+
+		// Same month of previous years, falls back to the norm when there is no history for it
+		var sameMonth []float64
+		for i := 1; i <= 3; i++ {
+			if total, ok := history[t][monthStart.AddDate(-i, 0, 0).Format("2006-01")]; ok {
+				sameMonth = append(sameMonth, total)
+			}
+		}
+		seasonal := norm
+		if len(sameMonth) > 0 {
+			seasonal, _ = meanAndDeviation(sameMonth)
+		}
+
+		mtd := toDate[t]
+		if mtd == 0 && norm == 0 && seasonal == 0 {
+			continue // type no longer used
+		}
+
+		pace := 0.0
+		if daysElapsed > 0 {
+			pace = mtd / float64(daysElapsed) * float64(daysInMonth)
+		}
+
+		projected := math.Max(mtd, progress*pace+(1-progress)*seasonal)
+		spread := deviation * math.Sqrt(1-progress)
+		known := false
+
+		if t == cardPaymentType && cardStatement > 0 {
+			projected = math.Max(mtd, cardStatement)
+			spread = 0
+			known = true
+		}
+
+		low := math.Max(mtd, projected-spread)
+		high := projected + spread
+
+		forecast := CategoryForecast{
+			Type:               t,
+			MonthToDate:        mtd,
+			FormattedToDate:    ec.FormatAmount(mtd),
+			PaceProjection:     pace,
+			SeasonalAverage:    seasonal,
+			HistoricalNorm:     norm,
+			Projected:          projected,
+			FormattedProjected: ec.FormatAmount(projected),
+			Low:                low,
+			High:               high,
+			FormattedRange:     fmt.Sprintf("%s - %s", ec.FormatAmount(low), ec.FormatAmount(high)),
+			ExceedsNorm:        norm > 0 && projected > norm+deviation,
+			KnownFromStatement: known,
+		}
+
+		if forecast.ExceedsNorm {
+			response.CategoriesOverNorm = append(response.CategoriesOverNorm, t)
+		}
+
+		response.MonthToDate += mtd
+		response.Projected += projected
+		response.Low += low
+		response.High += high
+		response.Categories = append(response.Categories, forecast)
+	}
+
+	sort.Slice(response.Categories, func(i, j int) bool {
+		return response.Categories[i].Projected > response.Categories[j].Projected
+	})
+	sort.Strings(response.CategoriesOverNorm)
+
+	response.FormattedProjected = ec.FormatAmount(response.Projected)
+	response.FormattedRange = fmt.Sprintf("%s - %s", ec.FormatAmount(response.Low), ec.FormatAmount(response.High))
+
+	c.JSON(http.StatusOK, response)
+}
+
+func meanAndDeviation(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
@@ -0,0 +1,316 @@
+package reports
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	transactions "finance-backend/controllers/base"
+)
+
+type ReportsController struct {
+	*transactions.BaseController // Embed base to share base methods
+}
+
+func NewReportsController() *ReportsController {
+	return &ReportsController{
+		BaseController: &transactions.BaseController{},
+	}
+}
+
+type CashflowBucket struct {
+	Period            string  `json:"period"`
+	Incomes           float64 `json:"incomes"`
+	FormattedIncomes  string  `json:"formatted_incomes"`
+	Expenses          float64 `json:"expenses"`
+	FormattedExpenses string  `json:"formatted_expenses"`
+	CardsARS          float64 `json:"cards_ars"`
+	FormattedCardsARS string  `json:"formatted_cards_ars"`
+	CardsUSD          float64 `json:"cards_usd"`
+	FormattedCardsUSD string  `json:"formatted_cards_usd"`
+	Net               float64 `json:"net"`
+	FormattedNet      string  `json:"formatted_net"`
+}
+
+type CashflowResponse struct {
+	From        string           `json:"from"`
+	To          string           `json:"to"`
+	Granularity string           `json:"granularity"`
+	AdjustedTo  string           `json:"adjusted_to,omitempty"` // base month when adjust=real
+	Tags        []string         `json:"tags,omitempty"`        // tag filter
+	Buckets     []CashflowBucket `json:"buckets"`
+	Totals      CashflowBucket   `json:"totals"`
+	TagTotals   []TagTotalItem   `json:"tag_totals"`
+}
+
+/*
+GetCashflow returns incomes, sheet expenses, card spending and net per bucket
+- from / to: YYYY-MM-DD (defaults to the last 12 months)
+- granularity: day | week | month (defaults to month)
+- adjust=real&base=YYYY-MM: restate peso amounts in constant pesos of the base month
+- tag: only the records tagged with any of the comma separated tags, card spending then
+sums the tagged line items instead of the statements
+Net follows the same rule as GetBalance: incomes minus sheet expenses, card
+statements are informative since they are paid through a sheet expense.
+*/
+func (ec *ReportsController) GetCashflow(c *gin.Context) {
+
+	now := time.Now()
+	defaultFrom := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -11, 0)
+
+	from, err := parseDateParam(c.Query("from"), defaultFrom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+
+	to, err := parseDateParam(c.Query("to"), time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from"})
+		return
+	}
+
+	granularity := c.DefaultQuery("granularity", "month")
+	if granularity != "day" && granularity != "week" && granularity != "month" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid granularity, expected day, week or month"})
+		return
+	}
+
+	adjuster, err := ec.GetInflationAdjuster(c, c.Query("adjust"), c.Query("base"))
+	if err != nil {
+		c.JSON(ec.InflationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	filter, err := ec.tagFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	buckets, err := ec.buildCashflow(c, from, to, granularity, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tagTotals, err := ec.tagTotals(c, from, to, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Every bucket key starts with YYYY-MM, which is the CPI month used to adjust it
+	if adjuster != nil {
+		for i := range buckets {
+			if err := adjustBucket(&buckets[i], adjuster); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	var totals CashflowBucket
+	for _, b := range buckets {
+		totals.Incomes += b.Incomes
+		totals.Expenses += b.Expenses
+		totals.CardsARS += b.CardsARS
+		totals.CardsUSD += b.CardsUSD
+	}
+	totals.Period = "total"
+	totals.Net = totals.Incomes - totals.Expenses
+
+	for i := range buckets {
+		ec.formatBucket(&buckets[i])
+	}
+	ec.formatBucket(&totals)
+
+	response := CashflowResponse{
+		From:        from.Format("2006-01-02"),
+		To:          to.Format("2006-01-02"),
+		Granularity: granularity,
+		Buckets:     buckets,
+		Totals:      totals,
+		TagTotals:   tagTotals,
+	}
+	if adjuster != nil {
+		response.AdjustedTo = adjuster.BaseMonth
+	}
+	if filter != nil {
+		response.Tags = filter.Tags
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// buildCashflow reads each table once for the whole range and spreads the rows over the buckets
+func (ec *ReportsController) buildCashflow(c *gin.Context, from time.Time, to time.Time, granularity string, filter *services.TagFilter) ([]CashflowBucket, error) {
+
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		return nil, err
+	}
+
+	cardsDB, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		return nil, err
+	}
+
+	fromStr := from.Format("2006-01-02")
+	toStr := to.Format("2006-01-02")
+
+	// Pre-create every bucket so the chart gets zeros instead of gaps
+	var buckets []CashflowBucket
+	index := make(map[string]int)
+	for d := bucketStart(from, granularity); !d.After(to); d = nextBucket(d, granularity) {
+		key := bucketKey(d, granularity)
+		index[key] = len(buckets)
+		buckets = append(buckets, CashflowBucket{Period: key})
+	}
+
+	// ---------- Sheet expenses ----------
+
+	var expenses []models.Expenses
+	var incomes []models.Incomes
+	err = services.WithCardsDatabase(db, cardsDB, func(tx *gorm.DB) error {
+		if err := filterByTags(tx.Select("date, amount"), filter, services.RecordSourceExpense).
+			Where("strftime('%Y-%m-%d', date) BETWEEN ? AND ?", fromStr, toStr).
+			Find(&expenses).Error; err != nil {
+			return fmt.Errorf("error fetching expenses: %w", err)
+		}
+		if err := filterByTags(tx.Select("date_time, amount"), filter, services.RecordSourceIncome).Find(&incomes).Error; err != nil {
+			return fmt.Errorf("error fetching incomes: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error at buildCashflow(): %w", err)
+	}
+
+	for _, e := range expenses {
+		if i, ok := index[bucketKey(e.Date, granularity)]; ok {
+			buckets[i].Expenses += e.Amount
+		}
+	}
+
+	// ---------- Incomes ----------
+
+	// date_time is stored in several layouts, so the range is applied after parsing it
+	for _, inc := range incomes {
+		date, err := ec.ParseTransactionDate(inc.DateTime)
+		if err != nil {
+			continue
+		}
+		if i, ok := index[bucketKey(date, granularity)]; ok {
+			buckets[i].Incomes += inc.Amount
+		}
+	}
+
+	// ---------- Card statements ----------
+
+	// With a tag filter the tagged line items are added instead of the statement totals
+	var resumes []models.Resume
+	if filter != nil {
+		items, err := services.LoadTaggedCardItems(cardsDB, filter, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			resumes = append(resumes, models.Resume{ResumeDate: item.ResumeDate, TotalARS: item.Amount})
+		}
+	} else if err := cardsDB.Select("resume_date, total_ars, total_usd").
+		Where("strftime('%Y-%m-%d', resume_date) BETWEEN ? AND ?", fromStr, toStr).
+		Find(&resumes).Error; err != nil {
+		return nil, fmt.Errorf("error fetching resumes at buildCashflow(): %w", err)
+	}
+
+	for _, r := range resumes {
+		date, err := time.Parse("2006-01-02", r.ResumeDate)
+		if err != nil {
+			continue
+		}
+		if i, ok := index[bucketKey(date, granularity)]; ok {
+			buckets[i].CardsARS += r.TotalARS
+			buckets[i].CardsUSD += r.TotalUSD
+		}
+	}
+
+	for i := range buckets {
+		buckets[i].Net = buckets[i].Incomes - buckets[i].Expenses
+	}
+
+	return buckets, nil
+}
+
+// adjustBucket restates the peso amounts of a bucket, USD card spending is left nominal
+func adjustBucket(b *CashflowBucket, adjuster *services.InflationAdjuster) error {
+	period := b.Period[:7]
+	var err error
+	if b.Incomes, err = adjuster.Adjust(b.Incomes, period); err != nil {
+		return err
+	}
+	if b.Expenses, err = adjuster.Adjust(b.Expenses, period); err != nil {
+		return err
+	}
+	if b.CardsARS, err = adjuster.Adjust(b.CardsARS, period); err != nil {
+		return err
+	}
+	b.Net = b.Incomes - b.Expenses
+	return nil
+}
+
+func (ec *ReportsController) formatBucket(b *CashflowBucket) {
+	b.FormattedIncomes = ec.FormatAmount(b.Incomes)
+	b.FormattedExpenses = ec.FormatAmount(b.Expenses)
+	b.FormattedCardsARS = ec.FormatAmount(b.CardsARS)
+	b.FormattedCardsUSD = ec.FormatAmount(b.CardsUSD)
+	b.FormattedNet = ec.FormatAmount(b.Net)
+}
+
+func parseDateParam(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// bucketStart truncates a date to the beginning of its bucket (weeks start on Monday)
+func bucketStart(t time.Time, granularity string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch granularity {
+	case "week":
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+func nextBucket(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+func bucketKey(t time.Time, granularity string) string {
+	if granularity == "month" {
+		return t.Format("2006-01")
+	}
+	return bucketStart(t, granularity).Format("2006-01-02")
+}
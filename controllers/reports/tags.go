@@ -0,0 +1,63 @@
+package reports
+
+import (
+	"finance-backend/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TagTotalItem struct {
+	services.TagTotal
+	FormattedExpenses string `json:"formatted_expenses"`
+	FormattedIncomes  string `json:"formatted_incomes"`
+	FormattedCards    string `json:"formatted_cards"`
+}
+
+// tagFilter reads the ?tag= param: comma separated tags, records with any of them are kept (nil when empty)
+func (ec *ReportsController) tagFilter(c *gin.Context) (*services.TagFilter, error) {
+	cardsDB, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		return nil, err
+	}
+	return services.NewTagFilter(cardsDB, c.Query("tag"))
+}
+
+/*
+filterByTags keeps the rows of a transactions query whose UUID is tagged, a nil filter keeps them all.
+The query has to run inside services.WithCardsDatabase, the tag links are in the cards database.
+*/
+func filterByTags(query *gorm.DB, filter *services.TagFilter, source string) *gorm.DB {
+	return filter.Scope(query, services.AttachedTagLinks, source, "uuid")
+}
+
+// tagTotals sums the tagged records between two dates (inclusive) for the tag_totals of the reports, only the filter tags with one
+func (ec *ReportsController) tagTotals(c *gin.Context, from time.Time, to time.Time, filter *services.TagFilter) ([]TagTotalItem, error) {
+
+	db, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		return nil, err
+	}
+
+	cardsDB, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := services.TagTotals(db, cardsDB, from, to, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]TagTotalItem, len(totals))
+	for i, total := range totals {
+		items[i] = TagTotalItem{
+			TagTotal:          total,
+			FormattedExpenses: ec.FormatAmount(total.Expenses),
+			FormattedIncomes:  ec.FormatAmount(total.Incomes),
+			FormattedCards:    ec.FormatAmount(total.Cards),
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,88 @@
+package settings
+
+import (
+	"errors"
+	"finance-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	transactions "finance-backend/controllers/base"
+)
+
+type SettingsController struct {
+	*transactions.BaseController // Embed base to share base methods
+}
+
+func NewSettingsController() *SettingsController {
+	return &SettingsController{
+		BaseController: &transactions.BaseController{},
+	}
+}
+
+type SettingRequest struct {
+	Value string `json:"value"`
+}
+
+/*
+GetSettings lists the settings that are not secret with their effective value and its source
+(default | file | env | database | household). Only the runtime ones can be changed from the API.
+The admins of the other households only see the ones they can override for themselves.
+*/
+func (ec *SettingsController) GetSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, services.SettingValues(ec.CurrentTenant(c)))
+}
+
+/*
+UpdateSetting stores a runtime setting in the database, it overrides the config file and the environment right away
+- body: {"value"}, checked against the kind of the setting, send a DELETE to go back to the previous value
+- the default household changes it for every household, the others only for themselves (see services.SaveSetting)
+*/
+func (ec *SettingsController) UpdateSetting(c *gin.Context) {
+
+	var request SettingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setting, err := services.SaveSetting(db, ec.CurrentTenant(c), c.Param("key"), request.Value)
+	if errors.Is(err, services.ErrInvalidSetting) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, setting)
+}
+
+// DeleteSetting removes a setting stored by the household, the shared, config file, env or default value applies again
+func (ec *SettingsController) DeleteSetting(c *gin.Context) {
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	deleted, err := services.DeleteSetting(db, ec.CurrentTenant(c), c.Param("key"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "setting not stored"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": c.Param("key")})
+}
@@ -0,0 +1,362 @@
+package tags
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	transactions "finance-backend/controllers/base"
+)
+
+type TagsController struct {
+	*transactions.BaseController // Embed base to share base methods
+}
+
+func NewTagsController() *TagsController {
+	return &TagsController{
+		BaseController: &transactions.BaseController{},
+	}
+}
+
+type TagWithUsage struct {
+	models.Tag
+	Records int64 `json:"records"`
+}
+
+type TagRecordsRequest struct {
+	Source string   `json:"source"` // expense | income | card
+	Keys   []string `json:"keys"`   // UUIDs, or "document/holder/position" for card line items
+}
+
+type BulkTagRequest struct {
+	Query   string   `json:"query"`   // text contained in the description (case insensitive)
+	Sources []string `json:"sources"` // expense | income | card, all of them by default
+	From    string   `json:"from"`    // YYYY-MM-DD, optional
+	To      string   `json:"to"`
+	Remove  bool     `json:"remove"` // untag the matching records instead
+}
+
+// GetTags lists the tags with how many records each one has
+func (ec *TagsController) GetTags(c *gin.Context) {
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tags := []TagWithUsage{}
+	if err := db.Model(&models.Tag{}).
+		Select("tags.*, COUNT(tag_links.id) AS records").
+		Joins("LEFT JOIN tag_links ON tag_links.tag_id = tags.id").
+		Group("tags.id").
+		Order("tags.name ASC").
+		Scan(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+/*
+CreateTag stores a new tag
+- body: {"name", "color"}, names are stored lowercase and must be unique
+*/
+func (ec *TagsController) CreateTag(c *gin.Context) {
+
+	var tag models.Tag
+	if err := c.ShouldBindJSON(&tag); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tag.ID = 0
+
+	if err := services.ValidateTag(&tag); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if ec.nameTaken(c, db, tag) {
+		return
+	}
+
+	if err := db.Create(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag renames or recolors a tag, its records keep it
+func (ec *TagsController) UpdateTag(c *gin.Context) {
+
+	tag, db, ok := ec.findTag(c)
+	if !ok {
+		return
+	}
+
+	var request models.Tag
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.ID = tag.ID
+	request.CreatedAt = tag.CreatedAt
+
+	if err := services.ValidateTag(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if ec.nameTaken(c, db, request) {
+		return
+	}
+
+	if err := db.Save(&request).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// DeleteTag removes a tag and untags every record
+func (ec *TagsController) DeleteTag(c *gin.Context) {
+
+	tag, db, ok := ec.findTag(c)
+	if !ok {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.TagLink{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": tag.ID})
+}
+
+/*
+GetTagRecords lists the records of a tag
+- source: expense | income | card (all by default)
+*/
+func (ec *TagsController) GetTagRecords(c *gin.Context) {
+
+	tag, db, ok := ec.findTag(c)
+	if !ok {
+		return
+	}
+
+	query := db.Where("tag_id = ?", tag.ID)
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	links := []models.TagLink{}
+	if err := query.Order("source ASC, record_key ASC").Find(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tag": tag, "records": links})
+}
+
+/*
+TagRecords tags (POST) or untags (DELETE) records by key
+- body: {"source": "expense" | "income" | "card", "keys": [...]}
+Keys that do not exist are returned as missing.
+*/
+func (ec *TagsController) TagRecords(c *gin.Context) {
+
+	tag, cardsDB, ok := ec.findTag(c)
+	if !ok {
+		return
+	}
+
+	var request TagRecordsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !services.ValidRecordSource(request.Source) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid source, expected expense, income or card"})
+		return
+	}
+
+	if c.Request.Method == http.MethodDelete {
+		removed, err := services.UnlinkTag(cardsDB, tag.ID, request.Source, request.Keys)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"removed": removed})
+		return
+	}
+
+	transactionsDB, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := services.ExistingRecordKeys(transactionsDB, cardsDB, request.Source, request.Keys)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	found := make(map[string]bool, len(existing))
+	for _, key := range existing {
+		found[key] = true
+	}
+	missing := []string{}
+	for _, key := range request.Keys {
+		if !found[key] {
+			missing = append(missing, key)
+		}
+	}
+
+	added, err := services.LinkTag(cardsDB, tag.ID, request.Source, existing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"added": added, "missing": missing})
+}
+
+/*
+BulkTag tags every record whose description contains a text, e.g. every "airbnb" of the trip
+- body: {"query", "sources": ["expense", "income", "card"], "from", "to" (YYYY-MM-DD), "remove"}
+*/
+func (ec *TagsController) BulkTag(c *gin.Context) {
+
+	tag, cardsDB, ok := ec.findTag(c)
+	if !ok {
+		return
+	}
+
+	var request BulkTagRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(request.Query) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+		return
+	}
+	if len(request.Sources) == 0 {
+		request.Sources = []string{services.RecordSourceExpense, services.RecordSourceIncome, services.RecordSourceCard}
+	}
+
+	var from, to time.Time
+	var err error
+	if request.From != "" {
+		if from, err = time.Parse("2006-01-02", request.From); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if request.To != "" {
+		if to, err = time.Parse("2006-01-02", request.To); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to, expected YYYY-MM-DD"})
+			return
+		}
+	}
+
+	transactionsDB, err := ec.GetDatabaseInstance(c, "TRANSACTION_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := gin.H{}
+	for _, source := range request.Sources {
+		if !services.ValidRecordSource(source) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid source, expected expense, income or card"})
+			return
+		}
+
+		keys, err := services.SearchRecordKeys(transactionsDB, cardsDB, source, request.Query, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		changed := 0
+		if request.Remove {
+			changed, err = services.UnlinkTag(cardsDB, tag.ID, source, keys)
+		} else {
+			changed, err = services.LinkTag(cardsDB, tag.ID, source, keys)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		result[source] = gin.H{"matched": len(keys), "changed": changed}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tag": tag.Name, "remove": request.Remove, "sources": result})
+}
+
+// findTag loads the tag of the :id param, answering the request when it is invalid or missing
+func (ec *TagsController) findTag(c *gin.Context) (models.Tag, *gorm.DB, bool) {
+
+	var tag models.Tag
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return tag, nil, false
+	}
+
+	db, err := ec.GetDatabaseInstance(c, "CARDS_DB")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return tag, nil, false
+	}
+
+	if err := db.Where("id = ?", id).Limit(1).Find(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return tag, nil, false
+	}
+	if tag.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag not found"})
+		return tag, nil, false
+	}
+	return tag, db, true
+}
+
+// nameTaken answers with a conflict when another tag already has the name
+func (ec *TagsController) nameTaken(c *gin.Context, db *gorm.DB, tag models.Tag) bool {
+	var existing int64
+	if err := db.Model(&models.Tag{}).Where("name = ? AND id <> ?", tag.Name, tag.ID).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return true
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "a tag with that name already exists"})
+		return true
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package tenants
+
+import (
+	"finance-backend/models"
+	"finance-backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	transactions "finance-backend/controllers/base"
+)
+
+type TenantsController struct {
+	*transactions.BaseController // Embed base to share base methods
+}
+
+func NewTenantsController() *TenantsController {
+	return &TenantsController{
+		BaseController: &transactions.BaseController{},
+	}
+}
+
+type TenantWithUsers struct {
+	models.Tenant
+	Users int64 `json:"users"`
+}
+
+// GetTenants lists the households hosted by the instance with how many users each one has
+func (ec *TenantsController) GetTenants(c *gin.Context) {
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenants := []TenantWithUsers{}
+	if err := db.Model(&models.Tenant{}).
+		Select("tenants.*, COUNT(users.id) AS users").
+		Joins("LEFT JOIN users ON users.tenant_id = tenants.id").
+		Group("tenants.id").
+		Order("tenants.id ASC").
+		Scan(&tenants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tenants)
+}
+
+/*
+CreateTenant adds a household with its own databases (TENANTS_PATH/<slug>, "tenants" by default)
+- body: {"slug", "name", "spreadsheet_id", "sheet_name", "statement_paths", "subscription_map", "subscription_logo_map", "specific_expenses_map", "specific_logo_map"}
+- statement_paths: "issuer:card_type:path" entries, comma separated, like CARD_STATEMENT_PATHS
+- the maps seed its merchant mappings, like the env maps of the default household
+Its first admin is created with POST /auth/users and its tenant_id.
+*/
+func (ec *TenantsController) CreateTenant(c *gin.Context) {
+
+	var tenant models.Tenant
+	if err := c.ShouldBindJSON(&tenant); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tenant.ID = 0
+
+	if err := services.ValidateTenant(&tenant); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var existing int64
+	if err := db.Model(&models.Tenant{}).Where("slug = ?", tenant.Slug).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "a household with that slug already exists"})
+		return
+	}
+
+	_, cardsDB, err := services.OpenTenantDatabases(tenant)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.Create(&tenant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	mappings, err := services.SeedMerchantMappings(cardsDB, tenant)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"tenant": tenant, "merchant_mappings": mappings})
+}
+
+/*
+UpdateTenant replaces the settings of a household, the slug cannot change since it names its files.
+The maps only seed an empty merchant_mappings table, use /cards/mappings to change the existing mappings.
+*/
+func (ec *TenantsController) UpdateTenant(c *gin.Context) {
+
+	tenant, db, ok := ec.findTenant(c)
+	if !ok {
+		return
+	}
+
+	var request models.Tenant
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.ID = tenant.ID
+	request.Slug = tenant.Slug
+	request.CreatedAt = tenant.CreatedAt
+
+	if err := services.ValidateTenant(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Save(&request).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// findTenant loads the household of the :id param, answering the request when it is invalid or missing
+func (ec *TenantsController) findTenant(c *gin.Context) (models.Tenant, *gorm.DB, bool) {
+
+	var tenant models.Tenant
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return tenant, nil, false
+	}
+
+	db, err := ec.GetSystemDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return tenant, nil, false
+	}
+
+	if err := db.Where("id = ?", id).Limit(1).Find(&tenant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return tenant, nil, false
+	}
+	if tenant.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "household not found"})
+		return tenant, nil, false
+	}
+	return tenant, db, true
+}
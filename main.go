@@ -6,10 +6,13 @@ import (
 	"time"
 
 	"finance-backend/config"
+	"finance-backend/models"
 	"finance-backend/routes"
+	"finance-backend/services"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // Define a new type based on string
@@ -37,55 +40,120 @@ func main() {
 	msg, err := MessageFormater(Yellow, "starting server...")
 	checkErrOrPrint(msg, err)
 
-	msg, err = MessageFormater(Yellow, "loading environment variables...")
+	msg, err = MessageFormater(Yellow, "loading configuration...")
 	checkErrOrPrint(msg, err)
-	config.LoadEnv()
+	if err := config.Load(); err != nil {
+		log.Fatal(MessageFormaterMust(Red, "Invalid configuration:\n"+err.Error()))
+	}
 
 	msg, err = MessageFormater(Yellow, "connecting to database...")
 	checkErrOrPrint(msg, err)
 
-	transactionsPath := config.GetEnv("TRANSACTIONS_DB_PATH")
-	_, err = config.ConnectDB("transactions", transactionsPath)
+	transactionsPath := config.String("TRANSACTIONS_DB_PATH")
+	transactionsDB, err := config.ConnectDB(config.String("TRANSACTION_DB"), transactionsPath)
 	if err != nil {
 		log.Fatal(MessageFormaterMust(Red, "Error trying to connect to transactions table: "+err.Error()))
 	}
 
-	cardsPath := config.GetEnv("CARDS_DB_PATH")
-	_, err = config.ConnectDB("cards", cardsPath)
+	cardsPath := config.String("CARDS_DB_PATH")
+	cardsDB, err := config.ConnectDB(config.String("CARDS_DB"), cardsPath)
 	if err != nil {
 		log.Fatal(MessageFormaterMust(Red, "Error trying to connect to cards table: "+err.Error()))
 	}
 
+	msg, err = MessageFormater(Yellow, "migrating tables...")
+	checkErrOrPrint(msg, err)
+
+	// Only tables added or changed after the initial schema are migrated, the original ones already exist on disk
+	if err := services.MigrateTenantDatabases(transactionsDB, cardsDB, false); err != nil {
+		log.Fatal(MessageFormaterMust(Red, "Error trying to migrate tables: "+err.Error()))
+	}
+	// Users, API tokens, households and settings are shared by every household, they live in the cards database of the default one
+	if err := cardsDB.AutoMigrate(&models.Tenant{}, &models.User{}, &models.UserHolder{}, &models.APIToken{}, &models.Setting{}, &models.TenantSetting{}); err != nil {
+		log.Fatal(MessageFormaterMust(Red, "Error trying to migrate users tables: "+err.Error()))
+	}
+
+	// Settings changed from the API override the config file and the environment
+	if err := services.LoadStoredSettings(cardsDB); err != nil {
+		log.Fatal(MessageFormaterMust(Red, "Invalid stored settings: "+err.Error()))
+	}
+
+	// Backfill installment plans for statements synced before they were tracked
+	var installmentPlans int64
+	cardsDB.Model(&models.InstallmentPlan{}).Count(&installmentPlans)
+	if installmentPlans == 0 {
+		if _, err := services.RebuildInstallmentPlans(cardsDB); err != nil {
+			log.Println(MessageFormaterMust(Red, "Error trying to backfill installment plans: "+err.Error()))
+		}
+	}
+
+	// The .env databases belong to the default household, the other ones have their own files under TENANTS_PATH
+	defaultTenant, err := services.SeedDefaultTenant(cardsDB)
+	if err != nil {
+		log.Fatal(MessageFormaterMust(Red, "Error trying to create the default household: "+err.Error()))
+	}
+	var tenants []models.Tenant
+	if err := cardsDB.Where("slug <> ?", services.DefaultTenantSlug).Find(&tenants).Error; err != nil {
+		log.Fatal(MessageFormaterMust(Red, "Error trying to fetch households: "+err.Error()))
+	}
+	for _, tenant := range tenants {
+		_, tenantCardsDB, err := services.OpenTenantDatabases(tenant)
+		if err != nil {
+			log.Fatal(MessageFormaterMust(Red, "Error trying to open the databases of household "+tenant.Slug+": "+err.Error()))
+		}
+		if _, err := services.SeedMerchantMappings(tenantCardsDB, tenant); err != nil {
+			log.Println(MessageFormaterMust(Red, "Error trying to seed merchant mappings of household "+tenant.Slug+": "+err.Error()))
+		}
+	}
+
+	if created, err := services.SeedAdminUser(cardsDB, defaultTenant); err != nil {
+		log.Fatal(MessageFormaterMust(Red, "Error trying to create the admin user: "+err.Error()))
+	} else if created {
+		log.Println(MessageFormaterMust(Cyan, "Created the first user from AUTH_ADMIN_USERNAME"))
+	}
+	if promoted, err := services.EnsureAdmin(cardsDB, defaultTenant); err != nil {
+		log.Fatal(MessageFormaterMust(Red, "Error trying to check the admin user: "+err.Error()))
+	} else if promoted {
+		log.Println(MessageFormaterMust(Cyan, "No user had the admin role, the oldest one was promoted"))
+	}
+	var users int64
+	cardsDB.Model(&models.User{}).Count(&users)
+	if users == 0 {
+		log.Println(MessageFormaterMust(Red, "There are no users, set AUTH_ADMIN_USERNAME and AUTH_ADMIN_PASSWORD to create the first one"))
+	}
+
+	// Keyword mappings live in the database, the env maps only seed an empty table
+	if _, err := services.SeedMerchantMappings(cardsDB, defaultTenant); err != nil {
+		log.Println(MessageFormaterMust(Red, "Error trying to seed merchant mappings: "+err.Error()))
+	}
+
+	// Periodic anomaly analysis, e.g. ANOMALY_SCAN_INTERVAL=6h (disabled when empty)
+	if every := config.Duration("ANOMALY_SCAN_INTERVAL"); every > 0 {
+		msg, err = MessageFormater(Yellow, "starting anomaly analysis job every "+every.String()+"...")
+		checkErrOrPrint(msg, err)
+		go runAnomalyJob(every, cardsDB)
+	}
+
 	msg, err = MessageFormater(Yellow, "setting routes...")
 	checkErrOrPrint(msg, err)
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
 
-	// ✅ CORS global habilitado (desarrollo o API pública)
-	r.Use(cors.New(cors.Config{
-		AllowAllOrigins:  true,
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-
-	/*
-		// 🔒 CORS restringido (para producción)
+	// CORS only for the origins in CORS_ALLOWED_ORIGINS (comma separated, e.g. "https://casapipis.net"),
+	// tokens travel in the Authorization header so no credentials are allowed
+	if origins := config.List("CORS_ALLOWED_ORIGINS"); len(origins) > 0 {
 		r.Use(cors.New(cors.Config{
-			AllowOrigins:     []string{"https://casapipis.net"},
-			AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-			AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-			ExposeHeaders:    []string{"Content-Length"},
-			AllowCredentials: true,
-			MaxAge:           12 * time.Hour,
+			AllowOrigins:  origins,
+			AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowHeaders:  []string{"Origin", "Content-Type", "Authorization"},
+			ExposeHeaders: []string{"Content-Length", "Content-Disposition"},
+			MaxAge:        12 * time.Hour,
 		}))
-	*/
+	}
 
 	routes.SetupRoutes(r)
 
-	port := config.GetEnv("PORT")
+	port := config.String("PORT")
 	portMsg := "Trying to serve HTTP on port..." + port
 	msg, err = MessageFormater(Cyan, portMsg)
 	checkErrOrPrint(msg, err)
@@ -94,6 +162,39 @@ func main() {
 
 }
 
+// runAnomalyJob scans every household, the list is read on each run so new households are included
+func runAnomalyJob(every time.Duration, systemDB *gorm.DB) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		var tenants []models.Tenant
+		if err := systemDB.Find(&tenants).Error; err != nil {
+			log.Println(MessageFormaterMust(Red, "Anomaly analysis failed: "+err.Error()))
+		}
+		for _, tenant := range tenants {
+			created, err := scanTenantAnomalies(tenant)
+			if err != nil {
+				log.Println(MessageFormaterMust(Red, "Anomaly analysis of household "+tenant.Slug+" failed: "+err.Error()))
+			} else if created > 0 {
+				log.Println(MessageFormaterMust(Cyan, fmt.Sprintf("Anomaly analysis found %d new anomalies in household %s", created, tenant.Slug)))
+			}
+		}
+		<-ticker.C
+	}
+}
+
+func scanTenantAnomalies(tenant models.Tenant) (int, error) {
+	transactionsDB, err := services.TenantDatabase(tenant, "TRANSACTION_DB")
+	if err != nil {
+		return 0, err
+	}
+	cardsDB, err := services.TenantDatabase(tenant, "CARDS_DB")
+	if err != nil {
+		return 0, err
+	}
+	return services.ScanAnomalies(transactionsDB, cardsDB)
+}
+
 func MessageFormater(color Color, message string) (string, error) {
 	val, ok := colorMap[color]
 	if ok {
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Anomaly is an alert raised by the analysis job over sheet expenses and card line items
+type Anomaly struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Kind        string     `gorm:"uniqueIndex:idx_anomaly_record" json:"kind"`       // outlier | duplicate | first_merchant
+	Source      string     `gorm:"uniqueIndex:idx_anomaly_record" json:"source"`     // expense | card
+	RecordKey   string     `gorm:"uniqueIndex:idx_anomaly_record" json:"record_key"` // expense UUID or "document_number/holder/position"
+	RelatedKey  string     `json:"related_key"`                                      // the other record of a duplicate
+	Date        string     `json:"date"`
+	Description string     `json:"description"`
+	Amount      float64    `json:"amount"`
+	Detail      string     `json:"detail"`
+	Score       float64    `json:"score"`
+	Status      string     `gorm:"index" json:"status"` // open | acknowledged | dismissed
+	DetectedAt  time.Time  `json:"detected_at"`
+	ResolvedAt  *time.Time `json:"resolved_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Attachment is a receipt or invoice file stored for an expense, income or card line item
+type Attachment struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Source       string    `gorm:"index:idx_attachment_record" json:"source"`     // expense | income | card
+	RecordKey    string    `gorm:"index:idx_attachment_record" json:"record_key"` // UUID, or "document/holder/position" of a card line item
+	FileName     string    `json:"file_name"`                                     // name of the uploaded file
+	ContentType  string    `json:"content_type"`                                  // sniffed from the content, not taken from the client
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	StorageKey   string    `json:"-"`
+	ThumbnailKey string    `json:"-"` // empty when there is no thumbnail (PDFs, undecodable images)
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// CardPayment is a payment made towards a card statement (resume)
+type CardPayment struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	DocumentNumber string    `gorm:"index" json:"document_number"` // resume paid
+	CardType       string    `json:"card_type"`
+	Date           string    `json:"date"` // formato: "2025-07-04"
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency"` // ARS | USD
+	Source         string    `json:"source"`   // manual | expense
+	ExpenseUUID    string    `gorm:"index" json:"expense_uuid"`
+	Note           string    `json:"note"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,7 @@
+package models
+
+// CPIIndex stores the consumer price index for a month, used to restate nominal pesos in constant pesos
+type CPIIndex struct {
+	Period string  `gorm:"primaryKey" json:"period"` // formato: "2025-07"
+	Value  float64 `json:"value"`
+}
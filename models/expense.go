@@ -10,4 +10,5 @@ type Expenses struct {
 	Description string    `json:"description"`
 	Amount      float64   `json:"amount"`
 	Type        string    `json:"type"`
+	Transfer    bool      `gorm:"not null;default:false" json:"transfer"` // card payment linked to the statement it settles
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ExpenseSplit shares a sheet expense or a card line item between household members
+type ExpenseSplit struct {
+	ID        uint                `gorm:"primaryKey" json:"id"`
+	Source    string              `gorm:"uniqueIndex:idx_split_record" json:"source"`     // expense | card
+	RecordKey string              `gorm:"uniqueIndex:idx_split_record" json:"record_key"` // expense UUID, or "document/holder/position" of a card line item
+	Method    string              `json:"method"`                                         // equal | percentage | fixed
+	Amount    float64             `json:"amount"`                                         // amount of the record when it was split
+	Shares    []ExpenseSplitShare `gorm:"foreignKey:SplitID" json:"shares"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// ExpenseSplitShare is the part of a split expense owed by a member
+type ExpenseSplitShare struct {
+	ID         uint    `gorm:"primaryKey" json:"-"`
+	SplitID    uint    `gorm:"index" json:"-"`
+	Member     string  `json:"member"`
+	Percentage float64 `json:"percentage"`
+	Amount     float64 `json:"amount"`
+}
@@ -8,4 +8,9 @@ type HolderExpense struct {
 	Description     string  `json:"description"`
 	Amount          float64 `json:"amount"`
 	FormattedAmount string  `json:"formatted_amount"`
+
+	// Installment (cuota) data parsed from the description, zero when it is a single payment
+	InstallmentNumber int   `json:"installment_number"`
+	InstallmentTotal  int   `json:"installment_total"`
+	InstallmentPlanID *uint `json:"installment_plan_id"`
 }
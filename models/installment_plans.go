@@ -0,0 +1,31 @@
+package models
+
+// InstallmentPlan groups the monthly occurrences (cuotas) of the same card purchase
+type InstallmentPlan struct {
+	ID                uint    `gorm:"primaryKey" json:"id"`
+	PlanKey           string  `gorm:"unique" json:"plan_key"` // card|holder|merchant|total|first month|amount
+	CardType          string  `json:"card_type"`
+	Holder            string  `json:"holder"`
+	Merchant          string  `json:"merchant"`
+	Description       string  `json:"description"` // description of the last occurrence
+	PurchaseDate      string  `json:"purchase_date"`
+	InstallmentAmount float64 `json:"installment_amount"`
+	TotalInstallments int     `json:"total_installments"`
+	LastInstallment   int     `json:"last_installment"` // highest installment number billed so far
+	FirstMonth        string  `json:"first_month"`      // statement month of installment 1, formato: "2025-07"
+	LastSeenMonth     string  `json:"last_seen_month"`
+	EndMonth          string  `json:"end_month"` // statement month of the last installment
+}
+
+// RemainingInstallments returns how many installments are still to be billed
+func (p InstallmentPlan) RemainingInstallments() int {
+	if p.LastInstallment >= p.TotalInstallments {
+		return 0
+	}
+	return p.TotalInstallments - p.LastInstallment
+}
+
+// RemainingAmount returns the amount still to be billed for the plan
+func (p InstallmentPlan) RemainingAmount() float64 {
+	return float64(p.RemainingInstallments()) * p.InstallmentAmount
+}
@@ -0,0 +1,12 @@
+package models
+
+// MerchantMapping classifies card line items whose description matches Pattern under Label
+type MerchantMapping struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Kind     string `gorm:"index" json:"kind"` // subscription | specific | category
+	Pattern  string `json:"pattern"`           // lowercase keyword, or a regular expression when IsRegex
+	IsRegex  bool   `json:"is_regex"`
+	Label    string `json:"label"`
+	Logo     string `json:"logo"`
+	Priority int    `json:"priority"` // higher priorities are tried first
+}
@@ -3,7 +3,13 @@ package models
 type Resume struct {
 	DocumentNumber    string   `gorm:"primaryKey" json:"document_number"`
 	CardType          string   `json:"card_type"`
+	FileHash          string   `gorm:"index" json:"file_hash"` // SHA-256 of the PDF, a statement is identified by card type + resume date
+	Version           int      `json:"version"`                // increased every time the statement is replaced
 	ResumeDate        string   // Cambiar de time.Time a string
+	ClosingDate       string   `json:"closing_date"` // formato: "2025-07-24", empty when the parser does not read it
+	DueDate           string   `json:"due_date"`
+	MinimumPayment    float64  `json:"minimum_payment"`
+	PreviousBalance   float64  `json:"previous_balance"`
 	TotalARS          float64  `json:"total_ars"`
 	TotalUSD          float64  `json:"total_usd"`
 	FormattedTotalARS string   `json:"formatted_total_ars"`
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Setting is a configuration value changed from the API, it overrides the config file and the environment
+type Setting struct {
+	Key       string    `gorm:"primaryKey" json:"key"` // env name, e.g. CARD_UPLOADS_PATH
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TenantSetting is a setting changed by the admin of a household, it overrides the shared value for that household only
+type TenantSetting struct {
+	TenantID  uint      `gorm:"primaryKey;autoIncrement:false" json:"tenant_id"`
+	Key       string    `gorm:"primaryKey" json:"key"` // a config.Setting marked as Household, e.g. CARD_PAYMENT_TYPE
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
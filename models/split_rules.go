@@ -0,0 +1,16 @@
+package models
+
+// SplitRule shares the card spending of a category between household members
+type SplitRule struct {
+	ID       uint         `gorm:"primaryKey" json:"id"`
+	Category string       `gorm:"uniqueIndex" json:"category"` // "*" applies to the categories without a rule of their own
+	Shares   []SplitShare `gorm:"foreignKey:RuleID" json:"shares"`
+}
+
+// SplitShare is the percentage of a split rule owed by a member
+type SplitShare struct {
+	ID         uint    `gorm:"primaryKey" json:"-"`
+	RuleID     uint    `gorm:"index" json:"-"`
+	Member     string  `json:"member"` // holder name as printed on the statements
+	Percentage float64 `json:"percentage"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Subscription is a recurring card charge detected across consecutive statements
+type Subscription struct {
+	ID              uint                `gorm:"primaryKey" json:"id"`
+	SubscriptionKey string              `gorm:"unique" json:"subscription_key"` // card|holder|merchant
+	CardType        string              `json:"card_type"`
+	Holder          string              `json:"holder"`
+	Merchant        string              `json:"merchant"`
+	Description     string              `json:"description"` // description of the last charge
+	Currency        string              `json:"currency"`    // ARS | USD
+	StartMonth      string              `json:"start_month"` // formato: "2025-07"
+	LastSeenMonth   string              `json:"last_seen_month"`
+	LastAmount      float64             `json:"last_amount"`
+	Status          string              `gorm:"index" json:"status"` // active | cancelled
+	CancelledAt     *time.Time          `json:"cancelled_at"`
+	Prices          []SubscriptionPrice `gorm:"foreignKey:SubscriptionID" json:"prices"`
+}
+
+// SubscriptionPrice is the amount charged by a subscription on a statement month
+type SubscriptionPrice struct {
+	ID             uint    `gorm:"primaryKey" json:"-"`
+	SubscriptionID uint    `gorm:"uniqueIndex:idx_subscription_month" json:"-"`
+	Month          string  `gorm:"uniqueIndex:idx_subscription_month" json:"month"`
+	Amount         float64 `json:"amount"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Tag is a free-form label for expenses, incomes and card line items, e.g. "vacaciones-2025"
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"unique" json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagLink attaches a tag to a record
+type TagLink struct {
+	ID        uint   `gorm:"primaryKey" json:"-"`
+	TagID     uint   `gorm:"uniqueIndex:idx_tag_record" json:"tag_id"`
+	Source    string `gorm:"uniqueIndex:idx_tag_record;index:idx_tag_source_record" json:"source"`     // expense | income | card
+	RecordKey string `gorm:"uniqueIndex:idx_tag_record;index:idx_tag_source_record" json:"record_key"` // UUID, or "document/holder/position" of a card line item
+}
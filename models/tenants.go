@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+/*
+Tenant is a household hosted by the instance, with its own databases, sheet and statements.
+The default household leaves the settings empty and keeps reading them from the .env file.
+*/
+type Tenant struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	Slug                string    `gorm:"unique" json:"slug"` // names the folders of its data, cannot change
+	Name                string    `json:"name"`
+	SpreadsheetID       string    `json:"spreadsheet_id"`
+	SheetName           string    `json:"sheet_name"`
+	StatementPaths      string    `json:"statement_paths"`       // "issuer:card_type:path" entries, comma separated
+	SubscriptionMap     string    `json:"subscription_map"`      // keyword:label pairs that seed the merchant mappings
+	SubscriptionLogoMap string    `json:"subscription_logo_map"` // label:logo pairs
+	SpecificExpensesMap string    `json:"specific_expenses_map"`
+	SpecificLogoMap     string    `json:"specific_logo_map"`
+	CreatedAt           time.Time `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// User is an account that can sign in to the API
+type User struct {
+	ID           uint         `gorm:"primaryKey" json:"id"`
+	Username     string       `gorm:"unique" json:"username"`
+	PasswordHash string       `json:"-"`                                   // bcrypt
+	TokenVersion int          `gorm:"not null;default:0" json:"-"`         // bumped on password changes to end the open sessions
+	Role         string       `gorm:"not null;default:viewer" json:"role"` // viewer | editor | admin
+	TenantID     uint         `gorm:"index" json:"tenant_id"`              // household whose data the user sees
+	Tenant       *Tenant      `json:"tenant,omitempty"`
+	Holders      []UserHolder `gorm:"foreignKey:UserID" json:"holders"` // card holders the user can see, all of them when empty
+	LastLoginAt  *time.Time   `json:"last_login_at"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// UserHolder restricts a user to the card line items of a holder
+type UserHolder struct {
+	ID     uint   `gorm:"primaryKey" json:"-"`
+	UserID uint   `gorm:"index" json:"-"`
+	Holder string `json:"holder"`
+}
+
+// APIToken is a personal token for scripts, only its SHA-256 is stored
+type APIToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index" json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // first characters of the token, to tell them apart
+	TokenHash  string     `gorm:"unique" json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at"` // nil when it does not expire
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -1,35 +1,136 @@
 package routes
 
 import (
+	"finance-backend/controllers/alerts"
+	"finance-backend/controllers/attachments"
+	"finance-backend/controllers/auth"
 	"finance-backend/controllers/balance"
 	"finance-backend/controllers/cards"
 	"finance-backend/controllers/expenses"
+	"finance-backend/controllers/holders"
 	"finance-backend/controllers/incomes"
+	"finance-backend/controllers/reports"
+	"finance-backend/controllers/settings"
+	"finance-backend/controllers/tags"
+	"finance-backend/controllers/tenants"
+	"finance-backend/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(r *gin.Engine) {
+func SetupRoutes(engine *gin.Engine) {
+
+	authController := auth.NewAuthController()
+	engine.POST("/auth/login", authController.Login)
+
+	// Every other route requires a session or personal API token, and the permission of the role
+	r := engine.Group("/", authController.RequireAuth)
+	read := authController.Require(services.PermissionRead)
+	readIncomes := authController.Require(services.PermissionReadIncomes)
+	write := authController.Require(services.PermissionWrite)
+	sync := authController.Require(services.PermissionSync)
+	manageUsers := authController.Require(services.PermissionManageUsers)
+	allHolders := authController.RequireAllHolders       // statement totals and data of every holder
+	defaultTenant := authController.RequireDefaultTenant // households are managed from the default one
+
+	r.GET("/auth/me", authController.GetMe)
+	r.POST("/auth/password", authController.ChangePassword)
+	r.GET("/auth/users", manageUsers, authController.GetUsers)
+	r.POST("/auth/users", manageUsers, authController.CreateUser)
+	r.PUT("/auth/users/:id", manageUsers, authController.UpdateUser)
+	r.DELETE("/auth/users/:id", manageUsers, authController.DeleteUser)
+	r.GET("/auth/tokens", authController.GetAPITokens)
+	r.POST("/auth/tokens", authController.CreateAPIToken)
+	r.DELETE("/auth/tokens/:id", authController.DeleteAPIToken)
+
+	tenantsController := tenants.NewTenantsController()
+	r.GET("/tenants", manageUsers, defaultTenant, tenantsController.GetTenants)
+	r.POST("/tenants", manageUsers, defaultTenant, tenantsController.CreateTenant)
+	r.PUT("/tenants/:id", manageUsers, defaultTenant, tenantsController.UpdateTenant)
+
+	settingsController := settings.NewSettingsController()
+	r.GET("/settings", manageUsers, settingsController.GetSettings)
+	r.PUT("/settings/:key", manageUsers, settingsController.UpdateSetting)
+	r.DELETE("/settings/:key", manageUsers, settingsController.DeleteSetting)
 
 	expenseController := expenses.NewExpenseController()
-	r.GET("/expenses", expenseController.GetExpenses)
-	r.GET("/expenses/recent", expenseController.GetExpenses)
-	r.GET("/expenses/summary", expenseController.GetExpensesSummary)
-	r.GET("/expenses/sync/month", expenseController.SyncCurrentMonthExpenses)
-	r.GET("/expenses/sync/historical", expenseController.SyncExpensesHistorical)
+	r.GET("/expenses", read, expenseController.GetExpenses)
+	r.GET("/expenses/recent", read, expenseController.GetExpenses)
+	r.GET("/expenses/summary", read, expenseController.GetExpensesSummary)
+	r.GET("/expenses/sync/month", sync, expenseController.SyncCurrentMonthExpenses)
+	r.GET("/expenses/sync/historical", sync, expenseController.SyncExpensesHistorical)
 
 	incomeController := incomes.NewIncomeController()
-	r.GET("/incomes", incomeController.GetIncomes)
-	r.GET("/incomes/sync/month", incomeController.SyncCurrentMonthIncomes)
-	r.GET("/incomes/sync/historical", incomeController.SyncIncomesHistorical)
+	r.GET("/incomes", readIncomes, incomeController.GetIncomes)
+	r.GET("/incomes/sync/month", sync, incomeController.SyncCurrentMonthIncomes)
+	r.GET("/incomes/sync/historical", sync, incomeController.SyncIncomesHistorical)
 
 	balanceController := balance.NewBalanceController()
-	r.GET("/balance", balanceController.GetBalance)
+	r.GET("/balance", readIncomes, allHolders, balanceController.GetBalance)
+
+	holderController := holders.NewHoldersController()
+	r.GET("/holders/:name/summary", read, authController.RequireHolderParam("name"), holderController.GetHolderSummary)
+	r.GET("/holders/settlement", read, allHolders, holderController.GetSettlement)
+	r.GET("/holders/split-rules", read, holderController.GetSplitRules)
+	r.POST("/holders/split-rules", write, holderController.CreateSplitRule)
+	r.PUT("/holders/split-rules/:id", write, holderController.UpdateSplitRule)
+	r.DELETE("/holders/split-rules/:id", write, holderController.DeleteSplitRule)
+	r.GET("/holders/splits", read, allHolders, holderController.GetExpenseSplits)
+	r.POST("/holders/splits", write, allHolders, holderController.SaveExpenseSplit)
+	r.DELETE("/holders/splits/:id", write, allHolders, holderController.DeleteExpenseSplit)
 
 	cardController := cards.NewCardsController()
-	r.GET("/cards/sync/resumes", cardController.SyncResumes)
-	r.GET("/cards/expenses", cardController.GetCardsExpenses)
-	r.GET("/cards/subscriptions", cardController.GetSubscriptionSummary)
-	r.GET("/cards/specificexpenses", cardController.GetSpecificCardExpenes)
-	r.GET("/cards/coutasexpire", cardController.GetCuotasAboutToExpire)
+	r.GET("/cards/sync/resumes", sync, allHolders, cardController.SyncResumes)
+	r.POST("/cards/resumes", sync, allHolders, cardController.UploadResume)
+	r.GET("/cards/due", read, allHolders, cardController.GetUpcomingDues)
+	r.GET("/cards/payments", read, allHolders, cardController.GetCardPayments)
+	r.POST("/cards/payments", write, allHolders, cardController.CreateCardPayment)
+	r.POST("/cards/payments/match", sync, allHolders, cardController.MatchCardPayments)
+	r.DELETE("/cards/payments/:id", write, allHolders, cardController.DeleteCardPayment)
+	r.GET("/cards/ledger", read, allHolders, cardController.GetCardLedger)
+	r.GET("/cards/reconciliation", read, allHolders, cardController.GetReconciliation)
+	r.POST("/cards/reconcile", sync, allHolders, cardController.ReconcileCardPayments)
+	r.GET("/cards/expenses", read, cardController.GetCardsExpenses)
+	r.GET("/cards/subscriptions", read, cardController.GetSubscriptionSummary)
+	r.GET("/cards/specificexpenses", read, cardController.GetSpecificCardExpenes)
+	r.GET("/cards/coutasexpire", read, cardController.GetCuotasAboutToExpire)
+	r.GET("/cards/installments", read, cardController.GetInstallmentPlans)
+	r.POST("/cards/installments/rebuild", sync, cardController.RebuildInstallmentPlans)
+	r.GET("/cards/commitments", read, allHolders, cardController.GetCardCommitments)
+	r.GET("/cards/subscriptions/detected", read, cardController.GetDetectedSubscriptions)
+	r.POST("/cards/subscriptions/detect", sync, cardController.DetectSubscriptions)
+	r.PATCH("/cards/subscriptions/detected/:id", write, cardController.UpdateSubscriptionStatus)
+	r.GET("/cards/mappings", read, cardController.GetMerchantMappings)
+	r.POST("/cards/mappings", write, cardController.CreateMerchantMapping)
+	r.PUT("/cards/mappings/:id", write, cardController.UpdateMerchantMapping)
+	r.DELETE("/cards/mappings/:id", write, cardController.DeleteMerchantMapping)
+
+	tagsController := tags.NewTagsController()
+	r.GET("/tags", read, tagsController.GetTags)
+	r.POST("/tags", write, tagsController.CreateTag)
+	r.PUT("/tags/:id", write, tagsController.UpdateTag)
+	r.DELETE("/tags/:id", write, tagsController.DeleteTag)
+	r.GET("/tags/:id/records", read, allHolders, tagsController.GetTagRecords)
+	r.POST("/tags/:id/records", write, allHolders, tagsController.TagRecords)
+	r.DELETE("/tags/:id/records", write, allHolders, tagsController.TagRecords)
+	r.POST("/tags/:id/bulk", write, allHolders, tagsController.BulkTag)
+
+	attachmentsController := attachments.NewAttachmentsController()
+	r.GET("/attachments", read, attachmentsController.GetAttachments)
+	r.POST("/attachments", write, attachmentsController.UploadAttachment)
+	r.GET("/attachments/:id", read, attachmentsController.DownloadAttachment)
+	r.DELETE("/attachments/:id", write, attachmentsController.DeleteAttachment)
+
+	reportsController := reports.NewReportsController()
+	r.GET("/reports/cashflow", readIncomes, allHolders, reportsController.GetCashflow)
+	r.GET("/reports/comparison", readIncomes, allHolders, reportsController.GetComparison)
+	r.GET("/reports/forecast", readIncomes, allHolders, reportsController.GetForecast)
+	r.GET("/reports/cpi", read, reportsController.GetCPIIndexes)
+	r.POST("/reports/cpi", write, reportsController.ImportCPIIndexes)
+
+	alertsController := alerts.NewAlertsController()
+	r.GET("/alerts/anomalies", read, allHolders, alertsController.GetAnomalies)
+	r.POST("/alerts/anomalies/scan", sync, allHolders, alertsController.ScanAnomalies)
+	r.POST("/alerts/anomalies/:id/acknowledge", write, allHolders, alertsController.AcknowledgeAnomaly)
+	r.POST("/alerts/anomalies/:id/dismiss", write, allHolders, alertsController.DismissAnomaly)
 }
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"finance-backend/models"
+	"finance-backend/utils"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	anomalyOutlierScore   = 3.0 // standard deviations above the mean
+	anomalyMinSamples     = 5   // minimum history of a category/merchant before flagging outliers
+	anomalyDuplicateDays  = 3   // window for duplicate charges
+	anomalyNearDuplicate  = 0.01
+	anomalyDateLayout     = "2006-01-02"
+	anomalyStatusOpen     = "open"
+	anomalySourceExpense  = "expense"
+	anomalySourceCardItem = "card"
+)
+
+// anomalyRecord is the common shape of sheet expenses and card line items for the detectors
+type anomalyRecord struct {
+	Source      string
+	Key         string
+	Group       string // expense type or normalized merchant
+	Merchant    string
+	Date        time.Time
+	Period      string // statement month for card items
+	Description string
+	Amount      float64
+	Installment bool
+}
+
+/*
+ScanAnomalies runs every detector over the sheet expenses and the card line items
+and stores the new findings. Already known anomalies keep their status, so
+dismissed alerts are not raised again. Returns how many new anomalies were stored.
+*/
+func ScanAnomalies(transactionsDB *gorm.DB, cardsDB *gorm.DB) (int, error) {
+
+	expenses, err := loadExpenseRecords(transactionsDB)
+	if err != nil {
+		return 0, err
+	}
+
+	cardItems, err := loadCardRecords(cardsDB)
+	if err != nil {
+		return 0, err
+	}
+
+	var anomalies []models.Anomaly
+	anomalies = append(anomalies, detectOutliers(expenses, "type")...)
+	anomalies = append(anomalies, detectOutliers(cardItems, "merchant")...)
+	anomalies = append(anomalies, detectDuplicates(expenses)...)
+	anomalies = append(anomalies, detectDuplicates(cardItems)...)
+	anomalies = append(anomalies, detectFirstTimeMerchants(cardItems)...)
+
+	return SaveAnomalies(cardsDB, anomalies)
+}
+
+// SaveAnomalies inserts the anomalies that are not stored yet (same kind, source and record)
+func SaveAnomalies(db *gorm.DB, anomalies []models.Anomaly) (int, error) {
+	created := 0
+	now := time.Now()
+	for _, anomaly := range anomalies {
+		anomaly.Status = anomalyStatusOpen
+		anomaly.DetectedAt = now
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&anomaly)
+		if result.Error != nil {
+			return created, fmt.Errorf("error saving anomaly at SaveAnomalies(): %w", result.Error)
+		}
+		created += int(result.RowsAffected)
+	}
+	return created, nil
+}
+
+func loadExpenseRecords(db *gorm.DB) ([]anomalyRecord, error) {
+	var expenses []models.Expenses
+	if err := db.Order("date ASC").Find(&expenses).Error; err != nil {
+		return nil, fmt.Errorf("error fetching expenses at loadExpenseRecords(): %w", err)
+	}
+
+	records := make([]anomalyRecord, 0, len(expenses))
+	for _, e := range expenses {
+		records = append(records, anomalyRecord{
+			Source:      anomalySourceExpense,
+			Key:         e.UUID,
+			Group:       e.Type,
+			Merchant:    utils.NormalizeMerchant(e.Description),
+			Date:        e.Date,
+			Period:      e.Date.Format("2006-01"),
+			Description: e.Description,
+			Amount:      e.Amount,
+		})
+	}
+	return records, nil
+}
+
+func loadCardRecords(db *gorm.DB) ([]anomalyRecord, error) {
+	var rows []struct {
+		DocumentNumber string
+		Holder         string
+		Position       int
+		Date           string
+		Description    string
+		Amount         float64
+		ResumeDate     string
+	}
+
+	if err := db.Table("holder_expenses AS e").
+		Select("e.document_number, e.holder, e.position, e.date, e.description, e.amount, r.resume_date").
+		Joins("JOIN resumes r ON e.document_number = r.document_number").
+		Order("e.date ASC").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error fetching card expenses at loadCardRecords(): %w", err)
+	}
+
+	records := make([]anomalyRecord, 0, len(rows))
+	for _, row := range rows {
+		date, _ := time.Parse(anomalyDateLayout, firstN(row.Date, 10))
+		merchant := utils.NormalizeMerchant(row.Description)
+		_, _, installment := utils.ParseInstallment(row.Description)
+		records = append(records, anomalyRecord{
+			Source:      anomalySourceCardItem,
+			Key:         fmt.Sprintf("%s/%s/%d", row.DocumentNumber, row.Holder, row.Position),
+			Group:       merchant,
+			Merchant:    merchant,
+			Date:        date,
+			Period:      firstN(row.ResumeDate, 7),
+			Description: row.Description,
+			Amount:      row.Amount,
+			Installment: installment,
+		})
+	}
+	return records, nil
+}
+
+// detectOutliers flags amounts far above the mean of their group
+func detectOutliers(records []anomalyRecord, groupName string) []models.Anomaly {
+	groups := make(map[string][]anomalyRecord)
+	for _, r := range records {
+		if r.Group != "" && r.Amount > 0 {
+			groups[r.Group] = append(groups[r.Group], r)
+		}
+	}
+
+	var anomalies []models.Anomaly
+	for group, items := range groups {
+		if len(items) < anomalyMinSamples {
+			continue
+		}
+
+		var sum, squares float64
+		for _, item := range items {
+			sum += item.Amount
+			squares += item.Amount * item.Amount
+		}
+
+		// Each amount is compared with the rest of its group, otherwise a big outlier inflates its own deviation
+		others := float64(len(items) - 1)
+		for _, item := range items {
+			mean := (sum - item.Amount) / others
+			variance := (squares-item.Amount*item.Amount)/others - mean*mean
+			if variance <= 0 {
+				continue
+			}
+			score := (item.Amount - mean) / math.Sqrt(variance)
+			if score < anomalyOutlierScore {
+				continue
+			}
+			anomalies = append(anomalies, newAnomaly("outlier", item, "", score,
+				fmt.Sprintf("amount is %.1f standard deviations above the %s %q average of %.2f", score, groupName, group, mean)))
+		}
+	}
+	return anomalies
+}
+
+// detectDuplicates flags charges of the same merchant and (almost) the same amount a few days apart
+func detectDuplicates(records []anomalyRecord) []models.Anomaly {
+	byMerchant := make(map[string][]anomalyRecord)
+	for _, r := range records {
+		// Installments repeat the original purchase date every month, they are not duplicates
+		if r.Merchant == "" || r.Installment || r.Date.IsZero() {
+			continue
+		}
+		byMerchant[r.Merchant] = append(byMerchant[r.Merchant], r)
+	}
+
+	var anomalies []models.Anomaly
+	for _, items := range byMerchant {
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Date.Before(items[j].Date) })
+
+		for i := 1; i < len(items); i++ {
+			for j := i - 1; j >= 0; j-- {
+				days := items[i].Date.Sub(items[j].Date).Hours() / 24
+				if days > anomalyDuplicateDays {
+					break
+				}
+				if items[j].Amount == 0 {
+					continue
+				}
+				difference := math.Abs(items[i].Amount-items[j].Amount) / math.Abs(items[j].Amount)
+				if difference > anomalyNearDuplicate {
+					continue
+				}
+
+				kind := "exact"
+				if difference > 0 {
+					kind = "near"
+				}
+				anomalies = append(anomalies, newAnomaly("duplicate", items[i], items[j].Key, 1-difference,
+					fmt.Sprintf("%s duplicate of %q charged on %s", kind, items[j].Description, items[j].Date.Format(anomalyDateLayout))))
+				break
+			}
+		}
+	}
+	return anomalies
+}
+
+// detectFirstTimeMerchants flags merchants of the latest statement month never seen on previous statements
+func detectFirstTimeMerchants(records []anomalyRecord) []models.Anomaly {
+	latest := ""
+	for _, r := range records {
+		if r.Period > latest {
+			latest = r.Period
+		}
+	}
+
+	seen := make(map[string]bool)
+	hasHistory := false
+	for _, r := range records {
+		if r.Period < latest {
+			seen[r.Merchant] = true
+			hasHistory = true
+		}
+	}
+
+	// Without previous statements every merchant would be new
+	if !hasHistory {
+		return nil
+	}
+
+	var anomalies []models.Anomaly
+	flagged := make(map[string]bool)
+	for _, r := range records {
+		if r.Period != latest || r.Merchant == "" || seen[r.Merchant] || flagged[r.Merchant] {
+			continue
+		}
+		flagged[r.Merchant] = true
+		anomalies = append(anomalies, newAnomaly("first_merchant", r, "", 1,
+			fmt.Sprintf("first charge from %q", r.Merchant)))
+	}
+	return anomalies
+}
+
+func newAnomaly(kind string, record anomalyRecord, related string, score float64, detail string) models.Anomaly {
+	date := ""
+	if !record.Date.IsZero() {
+		date = record.Date.Format(anomalyDateLayout)
+	}
+	return models.Anomaly{
+		Kind:        kind,
+		Source:      record.Source,
+		RecordKey:   record.Key,
+		RelatedKey:  related,
+		Date:        date,
+		Description: record.Description,
+		Amount:      record.Amount,
+		Detail:      detail,
+		Score:       math.Round(score*100) / 100,
+	}
+}
+
+func firstN(value string, n int) string {
+	if len(value) < n {
+		return value
+	}
+	return value[:n]
+}
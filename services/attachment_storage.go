@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"finance-backend/config"
+	"finance-backend/models"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrAttachmentFileNotFound = errors.New("attachment file not found")
+
+// AttachmentStorage keeps the content of the attachments, addressed by the storage key of each one
+type AttachmentStorage interface {
+	Save(key string, content []byte) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error // deleting a missing file is not an error
+}
+
+/*
+NewAttachmentStorage returns the storage configured by ATTACHMENTS_STORAGE ("local" by default).
+The local backend keeps the files under ATTACHMENTS_PATH ("attachments" by default),
+in a folder of their own for the households other than the default one (see TenantPath).
+*/
+func NewAttachmentStorage(tenant models.Tenant) (AttachmentStorage, error) {
+	switch backend := config.String("ATTACHMENTS_STORAGE"); backend {
+	case "local":
+		return &LocalAttachmentStorage{Root: TenantPath(tenant, config.String("ATTACHMENTS_PATH"))}, nil
+	default:
+		return nil, fmt.Errorf("unknown attachments storage %q, expected local", backend)
+	}
+}
+
+// LocalAttachmentStorage stores the attachments as files under Root
+type LocalAttachmentStorage struct {
+	Root string
+}
+
+// path resolves a key inside Root, rejecting keys that would escape it
+func (s *LocalAttachmentStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid attachment key %q", key)
+	}
+	return filepath.Join(s.Root, clean), nil
+}
+
+func (s *LocalAttachmentStorage) Save(key string, content []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating attachments directory at Save(): %w", err)
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return fmt.Errorf("error writing attachment at Save(): %w", err)
+	}
+	return nil
+}
+
+func (s *LocalAttachmentStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrAttachmentFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening attachment at Open(): %w", err)
+	}
+	return file, nil
+}
+
+func (s *LocalAttachmentStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting attachment at Delete(): %w", err)
+	}
+	return nil
+}
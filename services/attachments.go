@@ -0,0 +1,240 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"finance-backend/config"
+	"finance-backend/models"
+	"fmt"
+	"image"
+	_ "image/gif" // registers the decoder used by image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAttachmentMaxSizeMB = 10
+	thumbnailMaxSide           = 320
+	thumbnailMaxPixels         = 50_000_000 // larger images are stored without thumbnail
+)
+
+// attachmentTypes are the sniffed content types accepted as attachments, with the extension they are stored with
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+var (
+	ErrAttachmentTooLarge = errors.New("attachment is too large")
+	ErrAttachmentType     = errors.New("unsupported attachment type, expected a JPEG, PNG, GIF, WebP image or a PDF")
+)
+
+// MaxAttachmentSize returns the maximum size of an attachment of the household in bytes, ATTACHMENT_MAX_SIZE_MB (10 by default)
+func MaxAttachmentSize(tenant models.Tenant) int64 {
+	megabytes := config.TenantInt(tenant.ID, "ATTACHMENT_MAX_SIZE_MB")
+	if megabytes <= 0 {
+		megabytes = defaultAttachmentMaxSizeMB
+	}
+	return int64(megabytes) << 20
+}
+
+/*
+SaveAttachment stores a file for a record and, for images, a JPEG thumbnail of it
+- the content type is sniffed from the content, the extension and the client header are ignored
+- maxSize int64, bytes, see MaxAttachmentSize
+- returns ErrAttachmentTooLarge or ErrAttachmentType when the file is rejected
+*/
+func SaveAttachment(db *gorm.DB, storage AttachmentStorage, maxSize int64, source string, key string, fileName string, content []byte) (models.Attachment, error) {
+
+	if int64(len(content)) > maxSize {
+		return models.Attachment{}, ErrAttachmentTooLarge
+	}
+	contentType := http.DetectContentType(content)
+	extension, ok := attachmentTypes[contentType]
+	if !ok {
+		return models.Attachment{}, ErrAttachmentType
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	sum := sha256.Sum256(content)
+	folder := path.Join(source, time.Now().Format("2006-01"))
+
+	attachment := models.Attachment{
+		Source:      source,
+		RecordKey:   key,
+		FileName:    cleanFileName(fileName, extension),
+		ContentType: contentType,
+		Size:        int64(len(content)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		StorageKey:  path.Join(folder, name+extension),
+	}
+
+	if err := storage.Save(attachment.StorageKey, content); err != nil {
+		return attachment, err
+	}
+	if thumbnail, ok := buildThumbnail(content); ok {
+		thumbnailKey := path.Join(folder, name+"-thumb.jpg")
+		if err := storage.Save(thumbnailKey, thumbnail); err == nil {
+			attachment.ThumbnailKey = thumbnailKey
+		}
+	}
+
+	if err := db.Create(&attachment).Error; err != nil {
+		removeAttachmentFiles(storage, attachment)
+		return attachment, fmt.Errorf("error saving attachment at SaveAttachment(): %w", err)
+	}
+	return attachment, nil
+}
+
+// DeleteAttachments removes the attachments and their files, a file that cannot be removed does not stop the rest
+func DeleteAttachments(db *gorm.DB, storage AttachmentStorage, attachments []models.Attachment) error {
+
+	if len(attachments) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(attachments))
+	for i, attachment := range attachments {
+		ids[i] = attachment.ID
+	}
+	if err := db.Where("id IN ?", ids).Delete(&models.Attachment{}).Error; err != nil {
+		return fmt.Errorf("error deleting attachments at DeleteAttachments(): %w", err)
+	}
+
+	var failed []error
+	for _, attachment := range attachments {
+		if err := removeAttachmentFiles(storage, attachment); err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// DeleteRecordAttachments deletes the attachments of some records of a source, returns how many were deleted
+func DeleteRecordAttachments(db *gorm.DB, storage AttachmentStorage, source string, keys []string) (int, error) {
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	var attachments []models.Attachment
+	if err := db.Where("source = ? AND record_key IN ?", source, keys).Find(&attachments).Error; err != nil {
+		return 0, fmt.Errorf("error fetching attachments at DeleteRecordAttachments(): %w", err)
+	}
+	return len(attachments), DeleteAttachments(db, storage, attachments)
+}
+
+/*
+PruneAttachments deletes the attachments of a source whose record no longer exists,
+called after syncs remove records. Returns how many were deleted.
+*/
+func PruneAttachments(transactionsDB *gorm.DB, cardsDB *gorm.DB, storage AttachmentStorage, source string) (int, error) {
+
+	var attachments []models.Attachment
+	if err := cardsDB.Where("source = ?", source).Find(&attachments).Error; err != nil {
+		return 0, fmt.Errorf("error fetching attachments at PruneAttachments(): %w", err)
+	}
+	if len(attachments) == 0 {
+		return 0, nil
+	}
+
+	keys := make([]string, len(attachments))
+	for i, attachment := range attachments {
+		keys[i] = attachment.RecordKey
+	}
+	existing, err := ExistingRecordKeys(transactionsDB, cardsDB, source, keys)
+	if err != nil {
+		return 0, err
+	}
+	found := make(map[string]bool, len(existing))
+	for _, key := range existing {
+		found[key] = true
+	}
+
+	var orphans []models.Attachment
+	for _, attachment := range attachments {
+		if !found[attachment.RecordKey] {
+			orphans = append(orphans, attachment)
+		}
+	}
+	return len(orphans), DeleteAttachments(cardsDB, storage, orphans)
+}
+
+func removeAttachmentFiles(storage AttachmentStorage, attachment models.Attachment) error {
+	err := storage.Delete(attachment.StorageKey)
+	if attachment.ThumbnailKey != "" {
+		err = errors.Join(err, storage.Delete(attachment.ThumbnailKey))
+	}
+	return err
+}
+
+// buildThumbnail scales an image down to fit thumbnailMaxSide, false when the content is not a decodable image
+func buildThumbnail(content []byte) ([]byte, bool) {
+
+	size, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil || size.Width == 0 || size.Height == 0 || size.Width*size.Height > thumbnailMaxPixels {
+		return nil, false
+	}
+	source, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, false
+	}
+
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > thumbnailMaxSide || height > thumbnailMaxSide {
+		if width >= height {
+			width, height = thumbnailMaxSide, max(1, height*thumbnailMaxSide/bounds.Dx())
+		} else {
+			width, height = max(1, width*thumbnailMaxSide/bounds.Dy()), thumbnailMaxSide
+		}
+	}
+
+	// Nearest neighbour is enough to recognise a receipt
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sourceY := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			thumbnail.Set(x, y, source.At(bounds.Min.X+x*bounds.Dx()/width, sourceY))
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, thumbnail, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, false
+	}
+	return buffer.Bytes(), true
+}
+
+// cleanFileName keeps the base name of the uploaded file, falling back to "attachment" with the sniffed extension
+func cleanFileName(fileName string, extension string) string {
+	name := strings.TrimSpace(path.Base(strings.ReplaceAll(fileName, `\`, "/")))
+	if name == "" || name == "." || name == "/" {
+		return "attachment" + extension
+	}
+	if len(name) > 200 {
+		name = strings.ToValidUTF8(name[:200], "")
+	}
+	return name
+}
+
+func randomName() (string, error) {
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", fmt.Errorf("error generating attachment name at randomName(): %w", err)
+	}
+	return hex.EncodeToString(buffer), nil
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"finance-backend/config"
+	"finance-backend/models"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	APITokenPrefix         = "fb_"
+	defaultSessionDuration = 12 * time.Hour
+	minPasswordLength      = 10
+	minSessionSecretLength = 32
+	apiTokenTouchInterval  = time.Minute // how often last_used_at is refreshed
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+)
+
+var username = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{1,31}$`)
+
+// dummyHash is compared against when the user does not exist, so both cases take as long
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("finance-backend"), bcrypt.DefaultCost)
+
+type sessionClaims struct {
+	Subject   string `json:"sub"`
+	Username  string `json:"name"`
+	Version   int    `json:"ver"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// SessionSecret returns AUTH_SESSION_SECRET, the key used to sign the session tokens
+func SessionSecret() ([]byte, error) {
+	secret := config.String("AUTH_SESSION_SECRET")
+	if len(secret) < minSessionSecretLength {
+		return nil, fmt.Errorf("AUTH_SESSION_SECRET must be at least %d characters", minSessionSecretLength)
+	}
+	return []byte(secret), nil
+}
+
+// SessionDuration returns how long a login lasts, AUTH_SESSION_DURATION (12h by default)
+func SessionDuration() time.Duration {
+	duration := config.Duration("AUTH_SESSION_DURATION")
+	if duration <= 0 {
+		return defaultSessionDuration
+	}
+	return duration
+}
+
+// ValidateCredentials normalizes the username and checks both values before an account is stored
+func ValidateCredentials(user *models.User, password string) error {
+	user.Username = strings.ToLower(strings.TrimSpace(user.Username))
+	if !username.MatchString(user.Username) {
+		return errors.New("invalid username, expected 2 to 32 lowercase letters, digits, '.', '_' or '-'")
+	}
+	return ValidatePassword(password)
+}
+
+func ValidatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	if len(password) > 72 {
+		return errors.New("password must be at most 72 bytes") // bcrypt limit
+	}
+	return nil
+}
+
+// SetPassword hashes the password into the user and ends its open sessions
+func SetPassword(user *models.User, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("error hashing password at SetPassword(): %w", err)
+	}
+	user.PasswordHash = string(hash)
+	user.TokenVersion++
+	return nil
+}
+
+// CheckPassword compares a password with the stored hash
+func CheckPassword(user models.User, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+}
+
+// Login checks the credentials and returns the user with a new session token and its expiration
+func Login(db *gorm.DB, name string, password string) (models.User, string, time.Time, error) {
+
+	var user models.User
+	if err := db.Preload("Holders").Preload("Tenant").Where("username = ?", strings.ToLower(strings.TrimSpace(name))).Limit(1).Find(&user).Error; err != nil {
+		return user, "", time.Time{}, fmt.Errorf("error fetching user at Login(): %w", err)
+	}
+	if user.ID == 0 {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return user, "", time.Time{}, ErrInvalidCredentials
+	}
+	if !CheckPassword(user, password) {
+		return user, "", time.Time{}, ErrInvalidCredentials
+	}
+
+	token, expiresAt, err := IssueSessionToken(user)
+	if err != nil {
+		return user, "", time.Time{}, err
+	}
+
+	now := time.Now()
+	user.LastLoginAt = &now
+	if err := db.Model(&user).Update("last_login_at", now).Error; err != nil {
+		return user, "", time.Time{}, fmt.Errorf("error updating user at Login(): %w", err)
+	}
+	return user, token, expiresAt, nil
+}
+
+// IssueSessionToken signs a JWT (HS256) for the user, valid for SessionDuration
+func IssueSessionToken(user models.User) (string, time.Time, error) {
+
+	secret, err := SessionSecret()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(SessionDuration())
+	claims, err := json.Marshal(sessionClaims{
+		Subject:   strconv.FormatUint(uint64(user.ID), 10),
+		Username:  user.Username,
+		Version:   user.TokenVersion,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error encoding claims at IssueSessionToken(): %w", err)
+	}
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return payload + "." + signSession(secret, payload), expiresAt, nil
+}
+
+/*
+Authenticate resolves the user of a bearer token: a session JWT from Login or a personal
+API token (fb_...). Returns ErrInvalidToken when it is unknown, expired or revoked.
+*/
+func Authenticate(db *gorm.DB, token string) (models.User, error) {
+	if strings.HasPrefix(token, APITokenPrefix) {
+		return authenticateAPIToken(db, token)
+	}
+	return authenticateSession(db, token)
+}
+
+func authenticateSession(db *gorm.DB, token string) (models.User, error) {
+
+	var user models.User
+
+	secret, err := SessionSecret()
+	if err != nil {
+		return user, err
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return user, ErrInvalidToken
+	}
+	expected := signSession(secret, parts[0]+"."+parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return user, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHeader, &header) != nil || header.Alg != "HS256" {
+		return user, ErrInvalidToken
+	}
+
+	var claims sessionClaims
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(rawClaims, &claims) != nil {
+		return user, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return user, ErrInvalidToken
+	}
+	id, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return user, ErrInvalidToken
+	}
+
+	if err := db.Preload("Holders").Preload("Tenant").Where("id = ?", id).Limit(1).Find(&user).Error; err != nil {
+		return user, fmt.Errorf("error fetching user at Authenticate(): %w", err)
+	}
+	if user.ID == 0 || user.TokenVersion != claims.Version {
+		return models.User{}, ErrInvalidToken
+	}
+	return user, nil
+}
+
+func authenticateAPIToken(db *gorm.DB, token string) (models.User, error) {
+
+	var user models.User
+
+	var apiToken models.APIToken
+	if err := db.Where("token_hash = ?", HashAPIToken(token)).Limit(1).Find(&apiToken).Error; err != nil {
+		return user, fmt.Errorf("error fetching token at Authenticate(): %w", err)
+	}
+	now := time.Now()
+	if apiToken.ID == 0 || (apiToken.ExpiresAt != nil && !now.Before(*apiToken.ExpiresAt)) {
+		return user, ErrInvalidToken
+	}
+
+	if err := db.Preload("Holders").Preload("Tenant").Where("id = ?", apiToken.UserID).Limit(1).Find(&user).Error; err != nil {
+		return user, fmt.Errorf("error fetching user at Authenticate(): %w", err)
+	}
+	if user.ID == 0 {
+		return user, ErrInvalidToken
+	}
+
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) > apiTokenTouchInterval {
+		db.Model(&apiToken).Update("last_used_at", now)
+	}
+	return user, nil
+}
+
+// NewAPIToken generates a personal token, the plain value is only returned here
+func NewAPIToken(userID uint, name string, expiresAt *time.Time) (models.APIToken, string, error) {
+
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return models.APIToken{}, "", fmt.Errorf("error generating token at NewAPIToken(): %w", err)
+	}
+	plain := APITokenPrefix + base64.RawURLEncoding.EncodeToString(buffer)
+
+	return models.APIToken{
+		UserID:    userID,
+		Name:      strings.TrimSpace(name),
+		Prefix:    plain[:len(APITokenPrefix)+6],
+		TokenHash: HashAPIToken(plain),
+		ExpiresAt: expiresAt,
+	}, plain, nil
+}
+
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+SeedAdminUser creates the first account from AUTH_ADMIN_USERNAME / AUTH_ADMIN_PASSWORD
+in the default household when the users table is empty. Once there are users the env values are ignored.
+Returns whether the user was created.
+*/
+func SeedAdminUser(db *gorm.DB, tenant models.Tenant) (bool, error) {
+
+	var count int64
+	if err := db.Model(&models.User{}).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("error counting users at SeedAdminUser(): %w", err)
+	}
+	name, password := config.String("AUTH_ADMIN_USERNAME"), config.String("AUTH_ADMIN_PASSWORD")
+	if count > 0 || name == "" {
+		return false, nil
+	}
+
+	user := models.User{Username: name, Role: RoleAdmin, TenantID: tenant.ID}
+	if err := ValidateCredentials(&user, password); err != nil {
+		return false, fmt.Errorf("invalid AUTH_ADMIN_USERNAME / AUTH_ADMIN_PASSWORD: %w", err)
+	}
+	if err := SetPassword(&user, password); err != nil {
+		return false, err
+	}
+	if err := db.Create(&user).Error; err != nil {
+		return false, fmt.Errorf("error creating user at SeedAdminUser(): %w", err)
+	}
+	return true, nil
+}
+
+func signSession(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
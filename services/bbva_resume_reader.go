@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"finance-backend/config"
+	"finance-backend/models"
 	"fmt"
 	"io"
 	"log"
@@ -60,7 +61,8 @@ type Holders struct {
 // ---------- Main Reader ----------
 
 type PdfReaderBBVA struct {
-	service string
+	service string // optional, BBVA_PDF_SERVICE
+	native  *textStatementParser
 }
 
 type ResumePath struct {
@@ -75,23 +77,46 @@ type ResumeData struct {
 	FileName string
 }
 
-func NewPdfReaderBBVA() (*PdfReaderBBVA, error) {
+func NewPdfReaderBBVA(tenant models.Tenant) (*PdfReaderBBVA, error) {
 	return &PdfReaderBBVA{
-		service: config.GetEnv("BBVA_PDF_SERVICE"),
+		service: config.String("BBVA_PDF_SERVICE"),
+		native:  &textStatementParser{layout: bbvaLayout},
 	}, nil
 }
 
-func (reader *PdfReaderBBVA) ReadResumes(path ResumePath) ([]Holders, Totals, string, error) {
+/*
+ReadResumes parses a BBVA statement in process (see the golden files in testdata/statements).
+When the native parser fails or finds no holders and BBVA_PDF_SERVICE is configured, the PDF
+is sent to that service instead. The service does not read the closing and due dates nor the minimum payment.
+*/
+func (reader *PdfReaderBBVA) ReadResumes(path ResumePath) (*Statement, error) {
+
+	statement, err := reader.native.ReadResumes(path)
+	if err == nil && len(statement.Holders) > 0 {
+		return statement, nil
+	}
+	if reader.service == "" {
+		return statement, err
+	}
+
+	if err == nil {
+		err = fmt.Errorf("no holders found in %s", path.FileName)
+	}
+	log.Printf("native BBVA parser failed, using BBVA_PDF_SERVICE: %v", err)
+	return reader.readFromService(path)
+}
+
+// readFromService posts the PDF to the external BBVA service and parses its JSON answer
+func (reader *PdfReaderBBVA) readFromService(path ResumePath) (*Statement, error) {
 
 	if path.FilePath == "" {
-		return nil, Totals{}, "", fmt.Errorf("file path is empty")
+		return nil, fmt.Errorf("file path is empty")
 	}
 
-	file, err := os.Open(path.FilePath)
+	content, err := os.ReadFile(path.FilePath)
 	if err != nil {
-		return nil, Totals{}, "", fmt.Errorf("error opening file: %w", err)
+		return nil, fmt.Errorf("error opening file: %w", err)
 	}
-	defer file.Close()
 
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
@@ -102,58 +127,54 @@ func (reader *PdfReaderBBVA) ReadResumes(path ResumePath) ([]Holders, Totals, st
 
 	part, err := writer.CreatePart(header)
 	if err != nil {
-		return nil, Totals{}, "", fmt.Errorf("error creating form part: %w", err)
+		return nil, fmt.Errorf("error creating form part: %w", err)
 	}
 
-	_, err = io.Copy(part, file)
+	_, err = part.Write(content)
 	if err != nil {
-		return nil, Totals{}, "", fmt.Errorf("error copying file: %w", err)
+		return nil, fmt.Errorf("error copying file: %w", err)
 	}
 
 	if err := writer.Close(); err != nil {
-		return nil, Totals{}, "", fmt.Errorf("error closing writer: %w", err)
+		return nil, fmt.Errorf("error closing writer: %w", err)
 	}
 
 	req, err := http.NewRequest("POST", reader.service, body)
 	if err != nil {
-		return nil, Totals{}, "", fmt.Errorf("error creating request: %w", err)
+		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	client := &http.Client{}
 	res, err := client.Do(req)
 	if err != nil {
-		return nil, Totals{}, "", fmt.Errorf("error making request: %w", err)
+		return nil, fmt.Errorf("error making request: %w", err)
 	}
 	defer res.Body.Close()
 
 	responseJSON, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, Totals{}, "", fmt.Errorf("error reading response: %w", err)
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("BBVA service answered %s", res.Status)
 	}
 
-	hash, err := hashString(responseJSON)
+	// Same identity as the native parser, the file content
+	hash, err := hashString(content)
 	if err != nil {
-		return nil, Totals{}, "", fmt.Errorf("error hashing response: %w", err)
+		return nil, fmt.Errorf("error hashing file: %w", err)
 	}
 
 	holders, globalTotals, err := ParseCompleteResponse(responseJSON)
 	if err != nil {
-		return nil, Totals{}, "", err
+		return nil, err
 	}
-
-	for _, holder := range holders {
-		fmt.Printf("[%s]\n", holder.Holder)
-		for _, e := range holder.Expenses {
-			fmt.Printf("  %s - %s - %.2f\n", e.Date, e.Description, e.Amount)
-		}
-		fmt.Printf("Total: %.2f pesos / %.2f dollars\n\n", holder.Totals.ARS, holder.Totals.USD)
+	if len(holders) == 0 {
+		return nil, fmt.Errorf("BBVA service found no holders in %s", path.FileName)
 	}
 
-	fmt.Println("Global Totals:")
-	fmt.Printf("  %.2f pesos / %.2f dollars\n", globalTotals.ARS, globalTotals.USD)
-
-	return holders, globalTotals, hash, nil
+	return &Statement{Holders: holders, Totals: globalTotals, Hash: hash}, nil
 }
 
 // ---------- Helpers ----------
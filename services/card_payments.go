@@ -0,0 +1,216 @@
+package services
+
+import (
+	"finance-backend/models"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CardPaymentSourceManual  = "manual"
+	CardPaymentSourceExpense = "expense"
+	cardPaymentWindowDays    = 45   // a payment settles statements closed up to this many days before it
+	cardPaymentTolerance     = 0.01 // relative difference accepted when matching by amount
+	cardPaymentDateLayout    = "2006-01-02"
+)
+
+// CardPaymentType is the sheet expense type used for the card payments of the household (CARD_PAYMENT_TYPE, "Tarjeta" by default)
+func CardPaymentType(tenant models.Tenant) string {
+	paymentType := TenantSetting(tenant, "CARD_PAYMENT_TYPE")
+	if paymentType == "" {
+		paymentType = "Tarjeta"
+	}
+	return paymentType
+}
+
+// CardPaymentMatch is the result of MatchCardPayments
+type CardPaymentMatch struct {
+	Matched   []models.CardPayment `json:"matched"`
+	Unmatched []models.Expenses    `json:"unmatched"` // card payments of the sheet without a statement to settle
+	Removed   []models.CardPayment `json:"removed"`   // payments linked to sheet expenses that no longer exist
+}
+
+/*
+MatchCardPayments links the sheet expenses of the card payment type to the statements they pay.
+A payment settles a statement closed before it (up to 45 days), of the card named in its
+description when it names one. Statements whose outstanding balance or minimum payment
+equals the amount are preferred, otherwise the oldest statement still owed is paid.
+Expenses already linked are skipped, so it can run after every sync. Payments linked to
+an expense that was deleted from the sheet are removed first, their statements are owed again.
+- paymentType string, the sheet expense type of the card payments, see CardPaymentType
+*/
+func MatchCardPayments(transactionsDB *gorm.DB, cardsDB *gorm.DB, paymentType string) (CardPaymentMatch, error) {
+
+	result := CardPaymentMatch{Matched: []models.CardPayment{}, Unmatched: []models.Expenses{}, Removed: []models.CardPayment{}}
+
+	var expenses []models.Expenses
+	if err := transactionsDB.Where("type = ?", paymentType).Order("date ASC").Find(&expenses).Error; err != nil {
+		return result, fmt.Errorf("error fetching card payments at MatchCardPayments(): %w", err)
+	}
+
+	var resumes []models.Resume
+	if err := cardsDB.Order("resume_date ASC, card_type ASC").Find(&resumes).Error; err != nil {
+		return result, fmt.Errorf("error fetching resumes at MatchCardPayments(): %w", err)
+	}
+
+	var payments []models.CardPayment
+	if err := cardsDB.Find(&payments).Error; err != nil {
+		return result, fmt.Errorf("error fetching payments at MatchCardPayments(): %w", err)
+	}
+
+	payments, removed, err := removeOrphanCardPayments(transactionsDB, cardsDB, payments)
+	if err != nil {
+		return result, err
+	}
+	result.Removed = removed
+
+	linked := make(map[string]bool)
+	paid := make(map[string]float64)
+	for _, payment := range payments {
+		if payment.ExpenseUUID != "" {
+			linked[payment.ExpenseUUID] = true
+		}
+		if payment.Currency != "USD" {
+			paid[payment.DocumentNumber] += payment.Amount
+		}
+	}
+
+	for _, expense := range expenses {
+		if linked[expense.UUID] || expense.Amount <= 0 {
+			continue
+		}
+
+		resume, ok := statementForPayment(resumes, paid, expense)
+		if !ok {
+			result.Unmatched = append(result.Unmatched, expense)
+			continue
+		}
+
+		payment := models.CardPayment{
+			DocumentNumber: resume.DocumentNumber,
+			CardType:       resume.CardType,
+			Date:           expense.Date.Format(cardPaymentDateLayout),
+			Amount:         expense.Amount,
+			Currency:       "ARS",
+			Source:         CardPaymentSourceExpense,
+			ExpenseUUID:    expense.UUID,
+			Note:           expense.Description,
+		}
+		if err := cardsDB.Create(&payment).Error; err != nil {
+			return result, fmt.Errorf("error saving payment at MatchCardPayments(): %w", err)
+		}
+		paid[resume.DocumentNumber] += payment.Amount
+		result.Matched = append(result.Matched, payment)
+	}
+
+	return result, nil
+}
+
+// removeOrphanCardPayments deletes the payments linked to sheet expenses that no longer exist, returns the kept and the removed ones
+func removeOrphanCardPayments(transactionsDB *gorm.DB, cardsDB *gorm.DB, payments []models.CardPayment) ([]models.CardPayment, []models.CardPayment, error) {
+
+	var linked []string
+	for _, payment := range payments {
+		if payment.ExpenseUUID != "" {
+			linked = append(linked, payment.ExpenseUUID)
+		}
+	}
+	removed := []models.CardPayment{}
+	if len(linked) == 0 {
+		return payments, removed, nil
+	}
+
+	var existing []string
+	if err := transactionsDB.Model(&models.Expenses{}).Where("uuid IN ?", linked).Pluck("uuid", &existing).Error; err != nil {
+		return payments, removed, fmt.Errorf("error fetching linked expenses at MatchCardPayments(): %w", err)
+	}
+	exists := make(map[string]bool, len(existing))
+	for _, uuid := range existing {
+		exists[uuid] = true
+	}
+
+	kept := make([]models.CardPayment, 0, len(payments))
+	var removedIDs []uint
+	for _, payment := range payments {
+		if payment.ExpenseUUID != "" && !exists[payment.ExpenseUUID] {
+			removed = append(removed, payment)
+			removedIDs = append(removedIDs, payment.ID)
+			continue
+		}
+		kept = append(kept, payment)
+	}
+	if len(removedIDs) > 0 {
+		if err := cardsDB.Where("id IN ?", removedIDs).Delete(&models.CardPayment{}).Error; err != nil {
+			return payments, removed, fmt.Errorf("error deleting orphan payments at MatchCardPayments(): %w", err)
+		}
+	}
+	return kept, removed, nil
+}
+
+// statementForPayment picks the statement a sheet payment settles, see MatchCardPayments
+func statementForPayment(resumes []models.Resume, paid map[string]float64, expense models.Expenses) (models.Resume, bool) {
+
+	description := strings.ToLower(expense.Description)
+	namesCard := false
+	for _, resume := range resumes {
+		if resume.CardType != "" && strings.Contains(description, strings.ToLower(resume.CardType)) {
+			namesCard = true
+			break
+		}
+	}
+
+	var candidates []models.Resume
+	for _, resume := range resumes {
+		if namesCard && !strings.Contains(description, strings.ToLower(resume.CardType)) {
+			continue
+		}
+		closing, ok := StatementClosingDate(resume)
+		if !ok || closing.After(expense.Date) || expense.Date.Sub(closing) > cardPaymentWindowDays*24*time.Hour {
+			continue
+		}
+		if resume.TotalARS-paid[resume.DocumentNumber] <= cardPaymentTolerance {
+			continue
+		}
+		candidates = append(candidates, resume)
+	}
+	if len(candidates) == 0 {
+		return models.Resume{}, false
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, _ := StatementClosingDate(candidates[i])
+		b, _ := StatementClosingDate(candidates[j])
+		return a.Before(b)
+	})
+
+	for _, resume := range candidates {
+		outstanding := resume.TotalARS - paid[resume.DocumentNumber]
+		if amountsMatch(outstanding, expense.Amount) || amountsMatch(resume.MinimumPayment, expense.Amount) {
+			return resume, true
+		}
+	}
+	return candidates[0], true
+}
+
+// StatementClosingDate is the closing date of a statement, its period when the parser did not read one
+func StatementClosingDate(resume models.Resume) (time.Time, bool) {
+	if resume.ClosingDate != "" {
+		if date, err := time.Parse(cardPaymentDateLayout, resume.ClosingDate); err == nil {
+			return date, true
+		}
+	}
+	date, err := time.Parse(cardPaymentDateLayout, firstN(resume.ResumeDate, 10))
+	return date, err == nil
+}
+
+func amountsMatch(expected float64, amount float64) bool {
+	if expected <= 0 {
+		return false
+	}
+	return math.Abs(expected-amount) <= expected*cardPaymentTolerance
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"errors"
+	"finance-backend/models"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	HolderDefaultCategory      = "Otros"
+	HolderSubscriptionCategory = "Suscripciones"
+	SplitRuleDefaultCategory   = "*"
+)
+
+// HolderItem is a card line item with the statement it belongs to
+type HolderItem struct {
+	DocumentNumber string
+	CardType       string
+	ResumeDate     string
+	Holder         string
+	Position       int
+	Date           string
+	Description    string
+	Amount         float64
+}
+
+// HolderCategorizer assigns a category to card line items with the category mappings
+type HolderCategorizer struct {
+	categories    *MerchantMatcher
+	subscriptions *MerchantMatcher
+}
+
+// NewHolderCategorizer loads the category and subscription mappings
+func NewHolderCategorizer(db *gorm.DB) (*HolderCategorizer, error) {
+	categories, err := NewMerchantMatcher(db, "category")
+	if err != nil {
+		return nil, err
+	}
+	subscriptions, err := NewMerchantMatcher(db, "subscription")
+	if err != nil {
+		return nil, err
+	}
+	return &HolderCategorizer{categories: categories, subscriptions: subscriptions}, nil
+}
+
+/*
+Category returns the label of the category mapping matching the description.
+Line items only matched by a subscription mapping are "Suscripciones", the rest "Otros".
+*/
+func (h *HolderCategorizer) Category(description string) string {
+	if mapping, ok := h.categories.Match(description); ok {
+		return mapping.Label
+	}
+	if _, ok := h.subscriptions.Match(description); ok {
+		return HolderSubscriptionCategory
+	}
+	return HolderDefaultCategory
+}
+
+/*
+LoadHolderItems returns the card line items of the statements between two months (inclusive)
+- holder: only the line items of that holder (case insensitive), every holder when empty
+*/
+func LoadHolderItems(db *gorm.DB, from time.Time, to time.Time, holder string) ([]HolderItem, error) {
+
+	query := db.Table("holder_expenses AS e").
+		Select("e.document_number, r.card_type, r.resume_date, e.holder, e.position, e.date, e.description, e.amount").
+		Joins("JOIN resumes r ON e.document_number = r.document_number").
+		Where("strftime('%Y-%m', r.resume_date) BETWEEN ? AND ?", from.Format("2006-01"), to.Format("2006-01"))
+	if holder != "" {
+		query = query.Where("UPPER(TRIM(e.holder)) = ?", normalizeMember(holder))
+	}
+
+	var items []HolderItem
+	if err := query.Order("r.resume_date ASC, e.date ASC").Scan(&items).Error; err != nil {
+		return nil, fmt.Errorf("error fetching card expenses at LoadHolderItems(): %w", err)
+	}
+	return items, nil
+}
+
+/*
+HolderEffectiveItems returns what a holder spent on the cards between two months (inclusive):
+their own line items, and the line items split with them (see ExpenseSplit) on anyone's card,
+with the amount of their share.
+*/
+func HolderEffectiveItems(db *gorm.DB, from time.Time, to time.Time, holder string) ([]HolderItem, error) {
+
+	splits, err := LoadExpenseSplits(db, RecordSourceCard)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without splits only the holder line items count
+	scope := holder
+	if len(splits) > 0 {
+		scope = ""
+	}
+	items, err := LoadHolderItems(db, from, to, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	effective := make([]HolderItem, 0, len(items))
+	for _, item := range items {
+		if split, ok := splits[CardItemKey(item.DocumentNumber, item.Holder, item.Position)]; ok {
+			share, member := MemberShare(split, holder, item.Amount)
+			if !member {
+				continue
+			}
+			item.Amount = share
+		} else if normalizeMember(item.Holder) != normalizeMember(holder) {
+			continue
+		}
+		effective = append(effective, item)
+	}
+	return effective, nil
+}
+
+/*
+ValidateSplitRule checks a split rule before it is stored
+- category is required ("*" for the categories without a rule)
+- every member appears once with a positive percentage, and the percentages add up to 100
+*/
+func ValidateSplitRule(rule *models.SplitRule) error {
+	rule.Category = strings.TrimSpace(rule.Category)
+	if rule.Category == "" {
+		return errors.New("category is required")
+	}
+	if len(rule.Shares) == 0 {
+		return errors.New("shares are required")
+	}
+
+	seen := make(map[string]bool)
+	total := 0.0
+	for i := range rule.Shares {
+		rule.Shares[i].Member = strings.TrimSpace(rule.Shares[i].Member)
+		member := normalizeMember(rule.Shares[i].Member)
+		if member == "" {
+			return errors.New("member is required on every share")
+		}
+		if seen[member] {
+			return fmt.Errorf("member %q appears more than once", rule.Shares[i].Member)
+		}
+		if rule.Shares[i].Percentage <= 0 {
+			return fmt.Errorf("percentage of %q must be positive", rule.Shares[i].Member)
+		}
+		seen[member] = true
+		total += rule.Shares[i].Percentage
+	}
+	if math.Abs(total-100) > 0.01 {
+		return fmt.Errorf("percentages add up to %.2f, expected 100", total)
+	}
+	return nil
+}
+
+// SettlementMember is what a member paid on their cards for shared categories and what they owe
+type SettlementMember struct {
+	Name    string  `json:"name"`
+	Paid    float64 `json:"paid"`
+	Owed    float64 `json:"owed"`
+	Balance float64 `json:"balance"` // positive: the others owe them, negative: they owe the others
+}
+
+// SettlementTransfer is a payment between two members that settles the period
+type SettlementTransfer struct {
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Amount float64 `json:"amount"`
+}
+
+// Settlement is the statement of who owes whom for the shared card spending of a period
+type Settlement struct {
+	Members   []SettlementMember   `json:"members"`
+	Transfers []SettlementTransfer `json:"transfers"`
+}
+
+/*
+BuildSettlement splits the card line items of the period with their own split (see ExpenseSplit)
+or else the split rule of their category (the "*" rule when the category has none). Line items
+without a split or rule are personal spending and stay with their holder. The balances are then
+settled with as few transfers as possible, the largest debtor paying the largest creditor first.
+*/
+func BuildSettlement(db *gorm.DB, from time.Time, to time.Time) (Settlement, error) {
+
+	settlement := Settlement{Members: []SettlementMember{}, Transfers: []SettlementTransfer{}}
+
+	var rules []models.SplitRule
+	if err := db.Preload("Shares").Find(&rules).Error; err != nil {
+		return settlement, fmt.Errorf("error fetching split rules at BuildSettlement(): %w", err)
+	}
+	byCategory := make(map[string]models.SplitRule, len(rules))
+	for _, rule := range rules {
+		byCategory[strings.ToLower(rule.Category)] = rule
+	}
+
+	categorizer, err := NewHolderCategorizer(db)
+	if err != nil {
+		return settlement, err
+	}
+
+	splits, err := LoadExpenseSplits(db, RecordSourceCard)
+	if err != nil {
+		return settlement, err
+	}
+
+	items, err := LoadHolderItems(db, from, to, "")
+	if err != nil {
+		return settlement, err
+	}
+
+	members := make(map[string]*SettlementMember)
+	member := func(name string) *SettlementMember {
+		key := normalizeMember(name)
+		if members[key] == nil {
+			members[key] = &SettlementMember{Name: strings.TrimSpace(name)}
+		}
+		return members[key]
+	}
+
+	for _, item := range items {
+		if split, ok := splits[CardItemKey(item.DocumentNumber, item.Holder, item.Position)]; ok {
+			member(item.Holder).Paid += item.Amount
+			for _, share := range split.Shares {
+				amount, _ := MemberShare(split, share.Member, item.Amount)
+				member(share.Member).Owed += amount
+			}
+			continue
+		}
+
+		rule, ok := byCategory[strings.ToLower(categorizer.Category(item.Description))]
+		if !ok {
+			rule, ok = byCategory[SplitRuleDefaultCategory]
+		}
+		if !ok {
+			continue
+		}
+		member(item.Holder).Paid += item.Amount
+		for _, share := range rule.Shares {
+			member(share.Member).Owed += item.Amount * share.Percentage / 100
+		}
+	}
+
+	for _, m := range members {
+		m.Paid = roundCents(m.Paid)
+		m.Owed = roundCents(m.Owed)
+		m.Balance = roundCents(m.Paid - m.Owed)
+		settlement.Members = append(settlement.Members, *m)
+	}
+	sort.Slice(settlement.Members, func(i, j int) bool {
+		return settlement.Members[i].Balance > settlement.Members[j].Balance
+	})
+
+	settlement.Transfers = settleBalances(settlement.Members)
+	return settlement, nil
+}
+
+// settleBalances pairs debtors and creditors, members must be sorted by balance (creditors first)
+func settleBalances(members []SettlementMember) []SettlementTransfer {
+	transfers := []SettlementTransfer{}
+
+	balances := make([]float64, len(members))
+	for i, m := range members {
+		balances[i] = m.Balance
+	}
+
+	creditor, debtor := 0, len(members)-1
+	for creditor < debtor {
+		if balances[creditor] < 0.01 {
+			break
+		}
+		if balances[debtor] > -0.01 {
+			break
+		}
+		amount := math.Min(balances[creditor], -balances[debtor])
+		transfers = append(transfers, SettlementTransfer{
+			From:   members[debtor].Name,
+			To:     members[creditor].Name,
+			Amount: roundCents(amount),
+		})
+		balances[creditor] -= amount
+		balances[debtor] += amount
+		if balances[creditor] < 0.01 {
+			creditor++
+		}
+		if balances[debtor] > -0.01 {
+			debtor--
+		}
+	}
+	return transfers
+}
+
+func normalizeMember(name string) string {
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
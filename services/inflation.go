@@ -0,0 +1,155 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"finance-backend/models"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAdjustment = errors.New("invalid inflation adjustment")
+	ErrNoCPIIndex        = errors.New("no CPI index available")
+)
+
+// InflationAdjuster restates nominal amounts of a month in pesos of the base month
+type InflationAdjuster struct {
+	BaseMonth string
+	baseValue float64
+	periods   []string // sorted ascending, used to carry the last known index forward
+	index     map[string]float64
+}
+
+/*
+NewInflationAdjuster loads the CPI table and validates the base month
+- base string, month in YYYY-MM format
+*/
+func NewInflationAdjuster(db *gorm.DB, base string) (*InflationAdjuster, error) {
+
+	if _, err := time.Parse("2006-01", base); err != nil {
+		return nil, fmt.Errorf("%w: invalid base month %q, expected YYYY-MM", ErrInvalidAdjustment, base)
+	}
+
+	var rows []models.CPIIndex
+	if err := db.Order("period ASC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error fetching CPI index at NewInflationAdjuster(): %w", err)
+	}
+
+	adjuster := &InflationAdjuster{
+		BaseMonth: base,
+		index:     make(map[string]float64, len(rows)),
+	}
+	for _, row := range rows {
+		adjuster.index[row.Period] = row.Value
+		adjuster.periods = append(adjuster.periods, row.Period)
+	}
+
+	baseValue, err := adjuster.indexFor(base)
+	if err != nil {
+		return nil, err
+	}
+	adjuster.baseValue = baseValue
+
+	return adjuster, nil
+}
+
+/*
+Adjust converts an amount of the given month (YYYY-MM) to pesos of the base month.
+A nil adjuster returns the amount untouched so callers don't need to branch on it.
+Returns ErrNoCPIIndex for months before the first index.
+*/
+func (a *InflationAdjuster) Adjust(amount float64, period string) (float64, error) {
+	if a == nil {
+		return amount, nil
+	}
+	value, err := a.indexFor(period)
+	if err != nil {
+		return 0, err
+	}
+	return amount * a.baseValue / value, nil
+}
+
+// indexFor returns the index of the month, or the last published one before it (INDEC publishes with a delay)
+func (a *InflationAdjuster) indexFor(period string) (float64, error) {
+	if value, ok := a.index[period]; ok {
+		return value, nil
+	}
+	pos := sort.SearchStrings(a.periods, period)
+	if pos == 0 {
+		return 0, fmt.Errorf("%w for %s", ErrNoCPIIndex, period)
+	}
+	return a.index[a.periods[pos-1]], nil
+}
+
+/*
+ParseCPICSV reads "period,value" rows, period may be YYYY-MM or YYYY-MM-DD.
+A header row is skipped, values accept both "1234.5" and "1.234,5".
+A period can only appear once, YYYY-MM-DD dates of the same month count as the same period.
+*/
+func ParseCPICSV(reader io.Reader) ([]models.CPIIndex, error) {
+
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CPI csv: %w", err)
+	}
+
+	var indexes []models.CPIIndex
+	lines := make(map[string]int) // period -> line where it was read
+	for i, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+
+		period, err := parseCPIPeriod(record[0])
+		if err != nil {
+			if i == 0 {
+				continue // header
+			}
+			return nil, fmt.Errorf("invalid period %q at line %d", record[0], i+1)
+		}
+		if line, repeated := lines[period]; repeated {
+			return nil, fmt.Errorf("period %s at line %d is repeated, it was already at line %d", period, i+1, line)
+		}
+		lines[period] = i + 1
+
+		raw := strings.TrimSpace(record[1])
+		if strings.Contains(raw, ",") {
+			raw = strings.ReplaceAll(raw, ".", "")
+			raw = strings.ReplaceAll(raw, ",", ".")
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid value %q at line %d", record[1], i+1)
+		}
+
+		indexes = append(indexes, models.CPIIndex{Period: period, Value: value})
+	}
+
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("no CPI rows found on csv")
+	}
+
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Period < indexes[j].Period })
+	return indexes, nil
+}
+
+func parseCPIPeriod(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid period")
+}
@@ -0,0 +1,13 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCPICSVRepeatedPeriod(t *testing.T) {
+	_, err := ParseCPICSV(strings.NewReader("period,value\n2024-01,100\n2024-02,110\n2024-01-31,120\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 4") || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("ParseCPICSV() error = %v, want the repeated period with both lines", err)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"finance-backend/models"
+	"finance-backend/utils"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/*
+LinkInstallmentPlans parses the installment marker ("C.03/06") of every line item
+of a stored resume, fills the installment columns of holder_expenses and links
+each occurrence to its installment plan, creating the plan on its first occurrence.
+A plan is identified by card, holder, merchant, total installments, the statement
+month of installment 1 and the installment amount.
+*/
+func LinkInstallmentPlans(db *gorm.DB, resume models.Resume) error {
+
+	statementMonth, err := time.Parse("2006-01-02", resume.ResumeDate)
+	if err != nil {
+		return fmt.Errorf("invalid resume date %q at LinkInstallmentPlans(): %w", resume.ResumeDate, err)
+	}
+	month := statementMonth.Format("2006-01")
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, holder := range resume.Holders {
+			for _, expense := range holder.Expenses {
+
+				number, total, ok := utils.ParseInstallment(expense.Description)
+				if !ok {
+					continue
+				}
+
+				merchant := utils.NormalizeMerchant(expense.Description)
+				firstMonth := statementMonth.AddDate(0, -(number - 1), 0)
+				key := fmt.Sprintf("%s|%s|%s|%d|%s|%.0f", resume.CardType, holder.Holder, merchant, total, firstMonth.Format("2006-01"), math.Abs(expense.Amount))
+
+				var plan models.InstallmentPlan
+				if err := tx.Where("plan_key = ?", key).Limit(1).Find(&plan).Error; err != nil {
+					return fmt.Errorf("error fetching installment plan: %w", err)
+				}
+
+				if plan.ID == 0 {
+					plan = models.InstallmentPlan{
+						PlanKey:           key,
+						CardType:          resume.CardType,
+						Holder:            holder.Holder,
+						Merchant:          merchant,
+						PurchaseDate:      firstN(expense.Date, 10),
+						TotalInstallments: total,
+						FirstMonth:        firstMonth.Format("2006-01"),
+						EndMonth:          firstMonth.AddDate(0, total-1, 0).Format("2006-01"),
+					}
+				}
+
+				if number >= plan.LastInstallment {
+					plan.LastInstallment = number
+					plan.Description = expense.Description
+					plan.InstallmentAmount = expense.Amount
+				}
+				if month > plan.LastSeenMonth {
+					plan.LastSeenMonth = month
+				}
+
+				if err := tx.Save(&plan).Error; err != nil {
+					return fmt.Errorf("error saving installment plan: %w", err)
+				}
+
+				if err := tx.Model(&models.HolderExpense{}).
+					Where("document_number = ? AND holder = ? AND position = ?", expense.DocumentNumber, expense.Holder, expense.Position).
+					Updates(map[string]interface{}{
+						"installment_number":  number,
+						"installment_total":   total,
+						"installment_plan_id": plan.ID,
+					}).Error; err != nil {
+					return fmt.Errorf("error linking line item to installment plan: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+/*
+RebuildInstallmentPlans recreates every installment plan from the stored line items,
+used to backfill statements imported before plans existed. Returns the number of plans.
+*/
+func RebuildInstallmentPlans(db *gorm.DB) (int64, error) {
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.InstallmentPlan{}).Error; err != nil {
+			return fmt.Errorf("error deleting installment plans: %w", err)
+		}
+		if err := tx.Model(&models.HolderExpense{}).Where("1 = 1").
+			Updates(map[string]interface{}{"installment_number": 0, "installment_total": 0, "installment_plan_id": nil}).Error; err != nil {
+			return fmt.Errorf("error resetting line item installments: %w", err)
+		}
+
+		var resumes []models.Resume
+		if err := tx.Preload("Holders.Expenses").Order("resume_date ASC").Find(&resumes).Error; err != nil {
+			return fmt.Errorf("error fetching resumes: %w", err)
+		}
+
+		for _, resume := range resumes {
+			if err := LinkInstallmentPlans(tx, resume); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error at RebuildInstallmentPlans(): %w", err)
+	}
+
+	var count int64
+	db.Model(&models.InstallmentPlan{}).Count(&count)
+	return count, nil
+}
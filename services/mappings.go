@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"finance-backend/models"
+	"finance-backend/utils"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type mappingSeed struct {
+	Kind    string
+	Map     string // keyword:label pairs
+	LogoMap string // label:logo pairs
+}
+
+// mappingSeeds returns the maps used to seed the merchant_mappings table of a household the first time it is created
+func mappingSeeds(tenant models.Tenant) []mappingSeed {
+	return []mappingSeed{
+		{Kind: "subscription", Map: TenantValue(tenant, tenant.SubscriptionMap, "SUBSCRIPTION_MAP"), LogoMap: TenantValue(tenant, tenant.SubscriptionLogoMap, "SUBSCRIPTION_LOGO_MAP")},
+		{Kind: "specific", Map: TenantValue(tenant, tenant.SpecificExpensesMap, "SPECIFIC_EXPENSES_MAP"), LogoMap: TenantValue(tenant, tenant.SpecificLogoMap, "SPECIFIC_LOGO_MAP")},
+	}
+}
+
+type compiledMapping struct {
+	mapping models.MerchantMapping
+	regex   *regexp.Regexp
+}
+
+// MerchantMatcher classifies card line item descriptions with the stored mappings of a kind
+type MerchantMatcher struct {
+	mappings []compiledMapping
+}
+
+/*
+SeedMerchantMappings fills an empty merchant_mappings table with the keyword:label
+pairs of the household maps and their logo maps (the default household reads SUBSCRIPTION_MAP,
+SPECIFIC_EXPENSES_MAP and their logo maps from the env).
+Once the table has rows the maps are ignored. Returns the number of rows created.
+*/
+func SeedMerchantMappings(db *gorm.DB, tenant models.Tenant) (int, error) {
+
+	var count int64
+	if err := db.Model(&models.MerchantMapping{}).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("error counting merchant mappings at SeedMerchantMappings(): %w", err)
+	}
+	if count > 0 {
+		return 0, nil
+	}
+
+	var mappings []models.MerchantMapping
+	for _, seed := range mappingSeeds(tenant) {
+		logos := utils.ParseLogosMap(seed.LogoMap)
+		for keyword, label := range utils.ParseMap(seed.Map) {
+			mappings = append(mappings, models.MerchantMapping{
+				Kind:    seed.Kind,
+				Pattern: keyword,
+				Label:   label,
+				Logo:    logos[label],
+			})
+		}
+	}
+	if len(mappings) == 0 {
+		return 0, nil
+	}
+
+	if err := db.Create(&mappings).Error; err != nil {
+		return 0, fmt.Errorf("error seeding merchant mappings at SeedMerchantMappings(): %w", err)
+	}
+	return len(mappings), nil
+}
+
+/*
+ValidateMerchantMapping checks a mapping before it is stored
+- kind must be subscription, specific or category, pattern and label are required
+- keyword patterns are stored lowercase, regex patterns must compile
+*/
+func ValidateMerchantMapping(mapping *models.MerchantMapping) error {
+	mapping.Kind = strings.ToLower(strings.TrimSpace(mapping.Kind))
+	mapping.Pattern = strings.TrimSpace(mapping.Pattern)
+	mapping.Label = strings.TrimSpace(mapping.Label)
+	mapping.Logo = strings.TrimSpace(mapping.Logo)
+
+	if mapping.Kind != "subscription" && mapping.Kind != "specific" && mapping.Kind != "category" {
+		return errors.New("invalid kind, expected subscription, specific or category")
+	}
+	if mapping.Pattern == "" || mapping.Label == "" {
+		return errors.New("pattern and label are required")
+	}
+	if mapping.IsRegex {
+		if _, err := regexp.Compile("(?i)" + mapping.Pattern); err != nil {
+			return fmt.Errorf("invalid regex pattern: %w", err)
+		}
+	} else {
+		mapping.Pattern = strings.ToLower(mapping.Pattern)
+	}
+	return nil
+}
+
+/*
+NewMerchantMatcher loads the mappings of a kind (subscription | specific | category) in match order:
+higher priority first, then longer patterns, so overlapping keywords resolve the same way.
+*/
+func NewMerchantMatcher(db *gorm.DB, kind string) (*MerchantMatcher, error) {
+
+	var mappings []models.MerchantMapping
+	if err := db.Where("kind = ?", kind).
+		Order("priority DESC, LENGTH(pattern) DESC, pattern ASC, id ASC").
+		Find(&mappings).Error; err != nil {
+		return nil, fmt.Errorf("error fetching merchant mappings at NewMerchantMatcher(): %w", err)
+	}
+
+	matcher := &MerchantMatcher{}
+	for _, mapping := range mappings {
+		compiled := compiledMapping{mapping: mapping}
+		if mapping.IsRegex {
+			regex, err := regexp.Compile("(?i)" + mapping.Pattern)
+			if err != nil {
+				continue // stored before validation existed, never matches
+			}
+			compiled.regex = regex
+		}
+		matcher.mappings = append(matcher.mappings, compiled)
+	}
+	return matcher, nil
+}
+
+// Len returns how many mappings the matcher tries
+func (m *MerchantMatcher) Len() int {
+	return len(m.mappings)
+}
+
+// Match returns the first mapping matching the description
+func (m *MerchantMatcher) Match(description string) (models.MerchantMapping, bool) {
+	lower := strings.ToLower(description)
+	for _, compiled := range m.mappings {
+		if compiled.regex != nil {
+			if compiled.regex.MatchString(description) {
+				return compiled.mapping, true
+			}
+			continue
+		}
+		if strings.Contains(lower, compiled.mapping.Pattern) {
+			return compiled.mapping, true
+		}
+	}
+	return models.MerchantMapping{}, false
+}
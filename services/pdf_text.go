@@ -0,0 +1,991 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+/*
+Minimal PDF text extraction, enough for the text based card statements the banks send:
+- objects are located by scanning "N G obj" markers (object streams included), the xref table is not needed
+- FlateDecode, ASCIIHexDecode and ASCII85Decode streams
+- fonts with a ToUnicode CMap, otherwise single byte WinAnsi-like text
+Text fragments are placed with the text and graphics matrices and joined into lines
+top to bottom, left to right. Encrypted and scanned (image only) PDFs are not supported.
+*/
+
+var (
+	errPDFEncrypted = errors.New("encrypted PDFs are not supported")
+	errPDFNoText    = errors.New("no text found in the PDF")
+	pdfObjectMarker = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+)
+
+type pdfName string
+type pdfString []byte
+type pdfKeyword string
+type pdfArray []any
+type pdfDict map[pdfName]any
+
+type pdfRef struct {
+	Num int
+	Gen int
+}
+
+type pdfStream struct {
+	Dict pdfDict
+	Data []byte
+}
+
+type pdfDocument struct {
+	objects map[int]any
+}
+
+type pdfFont struct {
+	codeBytes  int               // 1 for simple fonts, 2 for composite (Type0) fonts
+	toUnicode  map[uint32]string // from the ToUnicode CMap
+	widths     map[uint32]float64
+	firstChar  int
+	widthsList []float64
+	dw         float64
+}
+
+type pdfTextFragment struct {
+	X, Y     float64
+	EndX     float64
+	FontSize float64
+	Text     string
+}
+
+// ExtractPDFText returns the text lines of every page of a PDF document, in reading order
+func ExtractPDFText(data []byte) ([]string, error) {
+
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \r\n\t"), []byte("%PDF")) {
+		return nil, errors.New("file is not a PDF")
+	}
+
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return nil, errPDFEncrypted
+	}
+	doc := parsePDFDocument(data)
+
+	var lines []string
+	for _, page := range doc.pages() {
+		lines = append(lines, doc.pageLines(page)...)
+	}
+	if len(lines) == 0 {
+		return nil, errPDFNoText
+	}
+	return lines, nil
+}
+
+// ---------- Document structure ----------
+
+func parsePDFDocument(data []byte) *pdfDocument {
+
+	doc := &pdfDocument{objects: make(map[int]any)}
+
+	// Later definitions win, which is how incremental updates work
+	for _, match := range pdfObjectMarker.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+		lexer := &pdfLexer{data: data, pos: match[1]}
+		value := lexer.value()
+
+		lexer.skipSpace()
+		if dict, ok := value.(pdfDict); ok && bytes.HasPrefix(data[lexer.pos:], []byte("stream")) {
+			value = &pdfStream{Dict: dict, Data: streamData(data, lexer.pos+len("stream"), dict)}
+		}
+		doc.objects[num] = value
+	}
+
+	// Objects packed inside object streams (PDF 1.5+)
+	for _, object := range doc.objects {
+		stream, ok := object.(*pdfStream)
+		if !ok || stream.Dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		decoded, err := decodeStream(stream)
+		if err != nil {
+			continue
+		}
+		count, _ := stream.Dict["N"].(float64)
+		first, _ := stream.Dict["First"].(float64)
+		header := &pdfLexer{data: decoded}
+		for i := 0; i < int(count); i++ {
+			num, _ := header.value().(float64)
+			offset, _ := header.value().(float64)
+			start := int(first) + int(offset)
+			if start < 0 || start >= len(decoded) {
+				break
+			}
+			if _, exists := doc.objects[int(num)]; !exists {
+				doc.objects[int(num)] = (&pdfLexer{data: decoded, pos: start}).value()
+			}
+		}
+	}
+
+	return doc
+}
+
+func streamData(data []byte, pos int, dict pdfDict) []byte {
+	if pos < 0 || pos > len(data) {
+		return nil
+	}
+	if bytes.HasPrefix(data[pos:], []byte("\r\n")) {
+		pos += 2
+	} else if pos < len(data) && (data[pos] == '\n' || data[pos] == '\r') {
+		pos++
+	}
+
+	if length, ok := dict["Length"].(float64); ok {
+		end := pos + int(length)
+		if length >= 0 && end >= pos && end <= len(data) && bytes.Contains(data[end:min(end+32, len(data))], []byte("endstream")) {
+			return data[pos:end]
+		}
+	}
+
+	end := bytes.Index(data[pos:], []byte("endstream"))
+	if end < 0 {
+		return data[pos:]
+	}
+	return bytes.TrimRight(data[pos:pos+end], "\r\n")
+}
+
+func (doc *pdfDocument) resolve(value any) any {
+	for i := 0; i < 8; i++ {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		value = doc.objects[ref.Num]
+	}
+	return value
+}
+
+func (doc *pdfDocument) dict(value any) pdfDict {
+	switch resolved := doc.resolve(value).(type) {
+	case pdfDict:
+		return resolved
+	case *pdfStream:
+		return resolved.Dict
+	}
+	return nil
+}
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages walks the page tree from the catalog, falling back to every page object in file order
+func (doc *pdfDocument) pages() []pdfPage {
+
+	var pages []pdfPage
+	var walk func(node pdfDict, resources pdfDict, depth int)
+	walk = func(node pdfDict, resources pdfDict, depth int) {
+		if node == nil || depth > 32 {
+			return
+		}
+		if own := doc.dict(node["Resources"]); own != nil {
+			resources = own
+		}
+		if node["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: node, resources: resources})
+			return
+		}
+		kids, _ := doc.resolve(node["Kids"]).(pdfArray)
+		for _, kid := range kids {
+			walk(doc.dict(kid), resources, depth+1)
+		}
+	}
+
+	for _, object := range doc.objects {
+		if catalog, ok := object.(pdfDict); ok && catalog["Type"] == pdfName("Catalog") {
+			walk(doc.dict(catalog["Pages"]), nil, 0)
+			break
+		}
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	nums := make([]int, 0, len(doc.objects))
+	for num := range doc.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if page, ok := doc.objects[num].(pdfDict); ok && page["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: page, resources: doc.dict(page["Resources"])})
+		}
+	}
+	return pages
+}
+
+func (doc *pdfDocument) pageContent(page pdfPage) []byte {
+	var content []byte
+	appendStream := func(value any) {
+		if stream, ok := doc.resolve(value).(*pdfStream); ok {
+			if decoded, err := decodeStream(stream); err == nil {
+				content = append(content, decoded...)
+				content = append(content, '\n')
+			}
+		}
+	}
+
+	switch contents := doc.resolve(page.dict["Contents"]).(type) {
+	case pdfArray:
+		for _, part := range contents {
+			appendStream(part)
+		}
+	default:
+		appendStream(page.dict["Contents"])
+	}
+	return content
+}
+
+// ---------- Streams ----------
+
+func decodeStream(stream *pdfStream) ([]byte, error) {
+	var filters []pdfName
+	switch filter := stream.Dict["Filter"].(type) {
+	case pdfName:
+		filters = []pdfName{filter}
+	case pdfArray:
+		for _, f := range filter {
+			if name, ok := f.(pdfName); ok {
+				filters = append(filters, name)
+			}
+		}
+	}
+
+	data := stream.Data
+	for _, filter := range filters {
+		switch filter {
+		case "FlateDecode", "Fl":
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("error inflating stream: %w", err)
+			}
+			// Streams with a broken checksum still carry the text
+			decoded, err := io.ReadAll(reader)
+			if err != nil && len(decoded) == 0 {
+				return nil, fmt.Errorf("error inflating stream: %w", err)
+			}
+			data = decoded
+		case "ASCIIHexDecode", "AHx":
+			cleaned := bytes.Map(func(r rune) rune {
+				if strings.ContainsRune("0123456789abcdefABCDEF", r) {
+					return r
+				}
+				return -1
+			}, bytes.TrimSuffix(bytes.TrimSpace(data), []byte(">")))
+			if len(cleaned)%2 == 1 {
+				cleaned = append(cleaned, '0')
+			}
+			decoded := make([]byte, hex.DecodedLen(len(cleaned)))
+			if _, err := hex.Decode(decoded, cleaned); err != nil {
+				return nil, fmt.Errorf("error decoding hex stream: %w", err)
+			}
+			data = decoded
+		case "ASCII85Decode", "A85":
+			trimmed := bytes.TrimSuffix(bytes.TrimSpace(data), []byte("~>"))
+			decoded := make([]byte, len(trimmed)*4/5+4)
+			n, _, err := ascii85.Decode(decoded, trimmed, true)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding ascii85 stream: %w", err)
+			}
+			data = decoded[:n]
+		default:
+			return nil, fmt.Errorf("unsupported stream filter %s", filter)
+		}
+	}
+	return data, nil
+}
+
+// ---------- Fonts ----------
+
+func (doc *pdfDocument) font(value any) *pdfFont {
+	dict := doc.dict(value)
+	font := &pdfFont{codeBytes: 1, dw: 1000}
+	if dict == nil {
+		return font
+	}
+
+	if dict["Subtype"] == pdfName("Type0") {
+		font.codeBytes = 2
+		if descendants, ok := doc.resolve(dict["DescendantFonts"]).(pdfArray); ok && len(descendants) > 0 {
+			descendant := doc.dict(descendants[0])
+			if dw, ok := descendant["DW"].(float64); ok {
+				font.dw = dw
+			}
+			font.widths = doc.cidWidths(descendant["W"])
+		}
+	} else {
+		first, _ := dict["FirstChar"].(float64)
+		font.firstChar = int(first)
+		if widths, ok := doc.resolve(dict["Widths"]).(pdfArray); ok {
+			for _, w := range widths {
+				width, _ := doc.resolve(w).(float64)
+				font.widthsList = append(font.widthsList, width)
+			}
+		}
+		font.dw = 500
+	}
+
+	if stream, ok := doc.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if cmap, err := decodeStream(stream); err == nil {
+			font.toUnicode, font.codeBytes = parseToUnicode(cmap, font.codeBytes)
+		}
+	}
+	return font
+}
+
+// cidWidths expands the W array of a CID font: "c [w1 w2 ...]" and "c_first c_last w" entries
+func (doc *pdfDocument) cidWidths(value any) map[uint32]float64 {
+	widths := make(map[uint32]float64)
+	list, _ := doc.resolve(value).(pdfArray)
+	for i := 0; i+1 < len(list); {
+		first, _ := list[i].(float64)
+		if group, ok := doc.resolve(list[i+1]).(pdfArray); ok {
+			for j, w := range group {
+				width, _ := w.(float64)
+				widths[uint32(first)+uint32(j)] = width
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(list) {
+			break
+		}
+		last, _ := list[i+1].(float64)
+		width, _ := list[i+2].(float64)
+		for code := uint32(first); code <= uint32(last) && code-uint32(first) < 65536; code++ {
+			widths[code] = width
+		}
+		i += 3
+	}
+	return widths
+}
+
+func (font *pdfFont) width(code uint32) float64 {
+	if font.codeBytes == 2 {
+		if width, ok := font.widths[code]; ok {
+			return width
+		}
+		return font.dw
+	}
+	index := int(code) - font.firstChar
+	if index >= 0 && index < len(font.widthsList) && font.widthsList[index] > 0 {
+		return font.widthsList[index]
+	}
+	return font.dw
+}
+
+// decode splits a shown string into character codes and their text
+func (font *pdfFont) decode(raw []byte) ([]uint32, []string) {
+	var codes []uint32
+	var texts []string
+	for i := 0; i < len(raw); i += font.codeBytes {
+		var code uint32
+		if font.codeBytes == 2 && i+1 < len(raw) {
+			code = uint32(raw[i])<<8 | uint32(raw[i+1])
+		} else {
+			code = uint32(raw[i])
+		}
+
+		text, ok := font.toUnicode[code]
+		if !ok {
+			if font.codeBytes == 2 {
+				text = string(rune(code))
+			} else {
+				text = winAnsiRune(byte(code))
+			}
+		}
+		codes = append(codes, code)
+		texts = append(texts, text)
+	}
+	return codes, texts
+}
+
+func winAnsiRune(b byte) string {
+	switch b {
+	case 0x80:
+		return "€"
+	case 0x91, 0x92:
+		return "'"
+	case 0x93, 0x94:
+		return "\""
+	case 0x96, 0x97:
+		return "-"
+	case 0xA0:
+		return " "
+	}
+	if b < 0x20 {
+		return ""
+	}
+	return string(rune(b)) // Latin-1 matches WinAnsi outside 0x80-0x9F
+}
+
+// parseToUnicode reads the bfchar/bfrange sections of a ToUnicode CMap
+func parseToUnicode(cmap []byte, codeBytes int) (map[uint32]string, int) {
+	mapping := make(map[uint32]string)
+	lexer := &pdfLexer{data: cmap}
+
+	var operands []any
+	for {
+		value, ok := lexer.next()
+		if !ok {
+			break
+		}
+		keyword, isKeyword := value.(pdfKeyword)
+		if !isKeyword {
+			operands = append(operands, value)
+			continue
+		}
+
+		switch keyword {
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if low, ok := operands[0].(pdfString); ok && len(low) > 0 {
+					codeBytes = len(low)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, _ := operands[i].(pdfString)
+				dst, _ := operands[i+1].(pdfString)
+				mapping[bytesCode(src)] = utf16BE(dst)
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				low, _ := operands[i].(pdfString)
+				high, _ := operands[i+1].(pdfString)
+				from, to := bytesCode(low), bytesCode(high)
+				if to < from || to-from > 65535 {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					base := []rune(utf16BE(dst))
+					if len(base) == 0 {
+						continue
+					}
+					// offsets instead of codes, from + offset would wrap around at 0xFFFFFFFF
+					for offset := uint32(0); offset <= to-from; offset++ {
+						shifted := append([]rune{}, base...)
+						shifted[len(shifted)-1] += rune(offset)
+						mapping[from+offset] = string(shifted)
+					}
+				case pdfArray:
+					for j, item := range dst {
+						if s, ok := item.(pdfString); ok && uint32(j) <= to-from {
+							mapping[from+uint32(j)] = utf16BE(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return mapping, codeBytes
+}
+
+func bytesCode(b []byte) uint32 {
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+func utf16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// ---------- Content streams ----------
+
+type pdfMatrix [6]float64
+
+var identityMatrix = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+func (m pdfMatrix) multiply(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (doc *pdfDocument) pageLines(page pdfPage) []string {
+
+	fonts := make(map[pdfName]*pdfFont)
+	fontDicts := doc.dict(page.resources["Font"])
+	fontFor := func(name pdfName) *pdfFont {
+		if font, ok := fonts[name]; ok {
+			return font
+		}
+		font := doc.font(fontDicts[name])
+		fonts[name] = font
+		return font
+	}
+
+	var (
+		fragments   []pdfTextFragment
+		operands    []any
+		ctm         = identityMatrix
+		stack       []pdfMatrix
+		tm, tlm     = identityMatrix, identityMatrix
+		font        = &pdfFont{codeBytes: 1, dw: 500}
+		fontSize    = 1.0
+		leading     = 0.0
+		charSpacing = 0.0
+		wordSpacing = 0.0
+		scale       = 1.0
+	)
+
+	number := func(i int) float64 {
+		if i < 0 || i >= len(operands) {
+			return 0
+		}
+		value, _ := operands[i].(float64)
+		return value
+	}
+	nextLine := func(tx, ty float64) {
+		tlm = pdfMatrix{1, 0, 0, 1, tx, ty}.multiply(tlm)
+		tm = tlm
+	}
+
+	show := func(raw []byte) {
+		codes, texts := font.decode(raw)
+		var text strings.Builder
+		start := pdfMatrix{1, 0, 0, 1, 0, 0}.multiply(tm).multiply(ctm)
+		for i, code := range codes {
+			text.WriteString(texts[i])
+			advance := font.width(code) / 1000 * fontSize
+			advance += charSpacing
+			if texts[i] == " " {
+				advance += wordSpacing
+			}
+			tm = pdfMatrix{1, 0, 0, 1, advance * scale, 0}.multiply(tm)
+		}
+		end := tm.multiply(ctm)
+		if strings.TrimSpace(text.String()) == "" {
+			return
+		}
+		size := fontSize * math.Hypot(start[2], start[3])
+		fragments = append(fragments, pdfTextFragment{X: start[4], Y: start[5], EndX: end[4], FontSize: size, Text: text.String()})
+	}
+
+	lexer := &pdfLexer{data: doc.pageContent(page)}
+	for {
+		value, ok := lexer.next()
+		if !ok {
+			break
+		}
+		op, isOp := value.(pdfKeyword)
+		if !isOp {
+			operands = append(operands, value)
+			continue
+		}
+
+		switch op {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			ctm = pdfMatrix{number(0), number(1), number(2), number(3), number(4), number(5)}.multiply(ctm)
+		case "BT":
+			tm, tlm = identityMatrix, identityMatrix
+		case "Tf":
+			if len(operands) >= 2 {
+				name, _ := operands[0].(pdfName)
+				font = fontFor(name)
+				fontSize = number(1)
+			}
+		case "Tc":
+			charSpacing = number(0)
+		case "Tw":
+			wordSpacing = number(0)
+		case "Tz":
+			scale = number(0) / 100
+		case "TL":
+			leading = number(0)
+		case "Td":
+			nextLine(number(0), number(1))
+		case "TD":
+			leading = -number(1)
+			nextLine(number(0), number(1))
+		case "Tm":
+			tlm = pdfMatrix{number(0), number(1), number(2), number(3), number(4), number(5)}
+			tm = tlm
+		case "T*":
+			nextLine(0, -leading)
+		case "Tj":
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					show(s)
+				}
+			}
+		case "'", "\"":
+			nextLine(0, -leading)
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					show(s)
+				}
+			}
+		case "TJ":
+			if len(operands) == 0 {
+				break
+			}
+			items, _ := operands[len(operands)-1].(pdfArray)
+			for _, item := range items {
+				switch v := item.(type) {
+				case pdfString:
+					show(v)
+				case float64:
+					// Large negative kerning is how many generators draw a word space
+					if v <= -250 {
+						fragments = append(fragments, pdfTextFragment{})
+					}
+					tm = pdfMatrix{1, 0, 0, 1, -v / 1000 * fontSize * scale, 0}.multiply(tm)
+				}
+			}
+		case "BI":
+			lexer.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+
+	return joinFragments(fragments)
+}
+
+// joinFragments groups the text fragments in lines by their baseline
+func joinFragments(all []pdfTextFragment) []string {
+
+	// Empty fragments mark a word space after the previous fragment
+	var fragments []pdfTextFragment
+	for _, fragment := range all {
+		if fragment.Text == "" {
+			if len(fragments) > 0 && !strings.HasSuffix(fragments[len(fragments)-1].Text, " ") {
+				fragments[len(fragments)-1].Text += " "
+			}
+			continue
+		}
+		fragments = append(fragments, fragment)
+	}
+
+	sort.SliceStable(fragments, func(i, j int) bool { return fragments[i].Y > fragments[j].Y })
+
+	var lines []string
+	for i := 0; i < len(fragments); {
+		tolerance := math.Max(fragments[i].FontSize*0.4, 1)
+		j := i + 1
+		for j < len(fragments) && fragments[i].Y-fragments[j].Y <= tolerance {
+			j++
+		}
+
+		line := fragments[i:j]
+		sort.SliceStable(line, func(a, b int) bool { return line[a].X < line[b].X })
+
+		var text strings.Builder
+		for k, fragment := range line {
+			if k > 0 {
+				gap := fragment.X - line[k-1].EndX
+				if gap > math.Max(fragment.FontSize*0.2, 0.5) && !strings.HasSuffix(text.String(), " ") && !strings.HasPrefix(fragment.Text, " ") {
+					text.WriteByte(' ')
+				}
+			}
+			text.WriteString(fragment.Text)
+		}
+		if joined := strings.Join(strings.Fields(text.String()), " "); joined != "" {
+			lines = append(lines, joined)
+		}
+		i = j
+	}
+	return lines
+}
+
+// ---------- Lexer ----------
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// next returns the next value or keyword, false at the end of the data
+func (l *pdfLexer) next() (any, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, false
+	}
+	start := l.pos
+	value := l.value()
+	if l.pos == start {
+		l.pos++ // unexpected delimiter, skip it
+		return l.next()
+	}
+	return value, true
+}
+
+func (l *pdfLexer) value() any {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		dict := make(pdfDict)
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				return dict
+			}
+			if bytes.HasPrefix(l.data[l.pos:], []byte(">>")) {
+				l.pos += 2
+				return dict
+			}
+			key, ok := l.value().(pdfName)
+			if !ok {
+				l.pos++
+				continue
+			}
+			dict[key] = l.value()
+		}
+	case c == '<':
+		l.pos++
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			end = len(l.data) - l.pos
+		}
+		digits := bytes.Map(func(r rune) rune {
+			if isPDFSpace(byte(r)) {
+				return -1
+			}
+			return r
+		}, l.data[l.pos:l.pos+end])
+		l.pos += end + 1
+		if len(digits)%2 == 1 {
+			digits = append(digits, '0')
+		}
+		decoded := make([]byte, hex.DecodedLen(len(digits)))
+		n, _ := hex.Decode(decoded, digits)
+		return pdfString(decoded[:n])
+	case c == '(':
+		return l.literalString()
+	case c == '[':
+		l.pos++
+		var array pdfArray
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				return array
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				return array
+			}
+			start := l.pos
+			array = append(array, l.value())
+			if l.pos == start {
+				l.pos++
+			}
+		}
+	case c == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfName(decodeNameEscapes(string(l.data[start:l.pos])))
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		number := l.number()
+		// "N G R" indirect reference
+		save := l.pos
+		l.skipSpace()
+		genStart := l.pos
+		for l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '9' {
+			l.pos++
+		}
+		if l.pos > genStart {
+			gen, _ := strconv.Atoi(string(l.data[genStart:l.pos]))
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == 'R' && (l.pos+1 == len(l.data) || isPDFSpace(l.data[l.pos+1]) || isPDFDelimiter(l.data[l.pos+1])) {
+				l.pos++
+				return pdfRef{Num: int(number), Gen: gen}
+			}
+		}
+		l.pos = save
+		return number
+	case isPDFDelimiter(c):
+		return nil
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	switch word := string(l.data[start:l.pos]); word {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	default:
+		return pdfKeyword(word)
+	}
+}
+
+func (l *pdfLexer) number() float64 {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.data) && (l.data[l.pos] == '.' || (l.data[l.pos] >= '0' && l.data[l.pos] <= '9')) {
+		l.pos++
+	}
+	value, _ := strconv.ParseFloat(string(l.data[start:l.pos]), 64)
+	return value
+}
+
+func (l *pdfLexer) literalString() pdfString {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					value := int(e - '0')
+					for k := 0; k < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; k++ {
+						value = value*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(value))
+				} else {
+					out = append(out, e)
+				}
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// skipInlineImage jumps over the binary data of a "BI ... ID <data> EI" inline image
+func (l *pdfLexer) skipInlineImage() {
+	id := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if id < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += id + 2
+	for l.pos < len(l.data) {
+		end := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if end < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		l.pos += end + 2
+		if isPDFSpace(l.data[l.pos-3]) && (l.pos == len(l.data) || isPDFSpace(l.data[l.pos])) {
+			return
+		}
+	}
+}
+
+func decodeNameEscapes(name string) string {
+	if !strings.Contains(name, "#") {
+		return name
+	}
+	var out strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if b, err := hex.DecodeString(name[i+1 : i+3]); err == nil {
+				out.WriteByte(b[0])
+				i += 2
+				continue
+			}
+		}
+		out.WriteByte(name[i])
+	}
+	return out.String()
+}
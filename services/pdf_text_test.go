@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreamDataNegativeLength(t *testing.T) {
+	data := []byte("stream\nabcdefgh\nendstream")
+	got := streamData(data, len("stream"), pdfDict{"Length": float64(-16)})
+	if string(got) != "abcdefgh" {
+		t.Fatalf("streamData() = %q, want %q", got, "abcdefgh")
+	}
+	if got := streamData(data, len(data)+4, pdfDict{}); got != nil {
+		t.Fatalf("streamData() past the end = %q, want nil", got)
+	}
+}
+
+func TestParseToUnicodeRangeAtTheEnd(t *testing.T) {
+	done := make(chan map[uint32]string, 1)
+	go func() {
+		mapping, _ := parseToUnicode([]byte("1 beginbfrange\n<FFFFFFF0> <FFFFFFFF> <0041>\nendbfrange"), 2)
+		done <- mapping
+	}()
+
+	select {
+	case mapping := <-done:
+		if len(mapping) != 16 || mapping[0xFFFFFFFF] != "P" {
+			t.Fatalf("parseToUnicode() mapped %d codes, 0xFFFFFFFF = %q", len(mapping), mapping[0xFFFFFFFF])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("parseToUnicode() does not end for a range that reaches 0xFFFFFFFF")
+	}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"finance-backend/models"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Permissions checked by the routes, see rolePermissions
+const (
+	PermissionRead        = "read"         // expenses, card line items, holders, tags, attachments, alerts
+	PermissionReadIncomes = "read_incomes" // incomes and anything that reveals them: balance, reports
+	PermissionWrite       = "write"        // tags, mappings, splits, payments, attachments, anomaly reviews
+	PermissionSync        = "sync"         // sheet and statement syncs, uploads, rebuilds and reconciliations
+	PermissionManageUsers = "manage_users"
+)
+
+var rolePermissions = map[string][]string{
+	RoleViewer: {PermissionRead},
+	RoleEditor: {PermissionRead, PermissionReadIncomes, PermissionWrite, PermissionSync},
+	RoleAdmin:  {PermissionRead, PermissionReadIncomes, PermissionWrite, PermissionSync, PermissionManageUsers},
+}
+
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission tells whether the role of the user grants the permission
+func HasPermission(user models.User, permission string) bool {
+	for _, granted := range rolePermissions[user.Role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedHolders returns the card holders the user is restricted to, nil when they can see every holder
+func AllowedHolders(user models.User) []string {
+	if len(user.Holders) == 0 {
+		return nil
+	}
+	holders := make([]string, len(user.Holders))
+	for i, holder := range user.Holders {
+		holders[i] = normalizeMember(holder.Holder)
+	}
+	return holders
+}
+
+// CanSeeHolder tells whether the holder is within the allowed ones (case insensitive), nil allows every holder
+func CanSeeHolder(allowed []string, holder string) bool {
+	if allowed == nil {
+		return true
+	}
+	for _, name := range allowed {
+		if name == normalizeMember(holder) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeHolders restricts a query to the rows whose holder column is within the allowed ones, nil keeps every row
+func ScopeHolders(query *gorm.DB, allowed []string, column string) *gorm.DB {
+	if allowed == nil {
+		return query
+	}
+	return query.Where("UPPER(TRIM("+column+")) IN ?", allowed)
+}
+
+// CardKeyHolder returns the holder of a CardItemKey ("document/holder/position")
+func CardKeyHolder(key string) string {
+	first := strings.Index(key, "/")
+	last := strings.LastIndex(key, "/")
+	if first < 0 || last <= first {
+		return ""
+	}
+	return key[first+1 : last]
+}
+
+// ValidateUserAccess normalizes the role and holders of a user before they are stored
+func ValidateUserAccess(user *models.User) error {
+	user.Role = strings.ToLower(strings.TrimSpace(user.Role))
+	if !ValidRole(user.Role) {
+		return errors.New("invalid role, expected viewer, editor or admin")
+	}
+	seen := make(map[string]bool)
+	holders := user.Holders[:0]
+	for _, holder := range user.Holders {
+		holder.ID = 0
+		holder.Holder = strings.TrimSpace(holder.Holder)
+		if holder.Holder == "" {
+			return errors.New("holder names cannot be empty")
+		}
+		if !seen[normalizeMember(holder.Holder)] {
+			seen[normalizeMember(holder.Holder)] = true
+			holders = append(holders, holder)
+		}
+	}
+	user.Holders = holders
+	return nil
+}
+
+/*
+EnsureAdmin promotes the oldest account of the household to admin when none of its accounts has the role,
+e.g. for users created before roles existed. Returns whether a user was promoted.
+*/
+func EnsureAdmin(db *gorm.DB, tenant models.Tenant) (bool, error) {
+
+	var admins int64
+	if err := db.Model(&models.User{}).Where("tenant_id = ? AND role = ?", tenant.ID, RoleAdmin).Count(&admins).Error; err != nil {
+		return false, fmt.Errorf("error counting admins at EnsureAdmin(): %w", err)
+	}
+	if admins > 0 {
+		return false, nil
+	}
+
+	var users []models.User
+	if err := db.Where("tenant_id = ?", tenant.ID).Order("id ASC").Limit(1).Find(&users).Error; err != nil {
+		return false, fmt.Errorf("error fetching users at EnsureAdmin(): %w", err)
+	}
+	if len(users) == 0 {
+		return false, nil
+	}
+	if err := db.Model(&users[0]).Update("role", RoleAdmin).Error; err != nil {
+		return false, fmt.Errorf("error promoting user at EnsureAdmin(): %w", err)
+	}
+	return true, nil
+}
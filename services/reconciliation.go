@@ -0,0 +1,121 @@
+package services
+
+import (
+	"finance-backend/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReconciledPayment is a card payment of the sheet linked to the statement it settles
+type ReconciledPayment struct {
+	Expense models.Expenses    `json:"expense"`
+	Payment models.CardPayment `json:"payment"`
+}
+
+// Reconciliation shows how the sheet card payments and the card statements match each other
+type Reconciliation struct {
+	Linked              []ReconciledPayment `json:"linked"`
+	UnmatchedExpenses   []models.Expenses   `json:"unmatched_expenses"`   // sheet payments without a statement
+	UnmatchedStatements []models.Resume     `json:"unmatched_statements"` // statements past due without any payment
+}
+
+/*
+ReconcileCardPayments links the sheet card payments to their statements (see MatchCardPayments)
+and marks the linked rows as transfers: the money moved from the account to the card,
+while what was spent is itemized in the statement. Rows whose link was removed stop
+being transfers. Returns the resulting reconciliation.
+*/
+func ReconcileCardPayments(transactionsDB *gorm.DB, cardsDB *gorm.DB, paymentType string) (Reconciliation, error) {
+
+	if _, err := MatchCardPayments(transactionsDB, cardsDB, paymentType); err != nil {
+		return Reconciliation{}, err
+	}
+
+	var linked []string
+	if err := cardsDB.Model(&models.CardPayment{}).Where("expense_uuid <> ''").Pluck("expense_uuid", &linked).Error; err != nil {
+		return Reconciliation{}, fmt.Errorf("error fetching linked payments at ReconcileCardPayments(): %w", err)
+	}
+
+	err := transactionsDB.Transaction(func(tx *gorm.DB) error {
+		unlink := tx.Model(&models.Expenses{}).Where("transfer = ?", true)
+		if len(linked) > 0 {
+			unlink = unlink.Where("uuid NOT IN ?", linked)
+		}
+		if err := unlink.Update("transfer", false).Error; err != nil {
+			return err
+		}
+		if len(linked) == 0 {
+			return nil
+		}
+		return tx.Model(&models.Expenses{}).Where("uuid IN ?", linked).Update("transfer", true).Error
+	})
+	if err != nil {
+		return Reconciliation{}, fmt.Errorf("error marking transfers at ReconcileCardPayments(): %w", err)
+	}
+
+	return GetReconciliation(transactionsDB, cardsDB, paymentType, time.Now().UTC())
+}
+
+// GetReconciliation reports the linked and unmatched items on both sides without changing anything
+func GetReconciliation(transactionsDB *gorm.DB, cardsDB *gorm.DB, paymentType string, today time.Time) (Reconciliation, error) {
+
+	result := Reconciliation{
+		Linked:              []ReconciledPayment{},
+		UnmatchedExpenses:   []models.Expenses{},
+		UnmatchedStatements: []models.Resume{},
+	}
+
+	var expenses []models.Expenses
+	if err := transactionsDB.Where("type = ?", paymentType).Order("date ASC").Find(&expenses).Error; err != nil {
+		return result, fmt.Errorf("error fetching card payments at GetReconciliation(): %w", err)
+	}
+
+	var payments []models.CardPayment
+	if err := cardsDB.Order("date ASC, id ASC").Find(&payments).Error; err != nil {
+		return result, fmt.Errorf("error fetching payments at GetReconciliation(): %w", err)
+	}
+
+	var resumes []models.Resume
+	if err := cardsDB.Order("resume_date ASC, card_type ASC").Find(&resumes).Error; err != nil {
+		return result, fmt.Errorf("error fetching resumes at GetReconciliation(): %w", err)
+	}
+
+	byExpense := make(map[string]models.CardPayment)
+	paidResumes := make(map[string]bool)
+	for _, payment := range payments {
+		paidResumes[payment.DocumentNumber] = true
+		if payment.ExpenseUUID != "" {
+			byExpense[payment.ExpenseUUID] = payment
+		}
+	}
+
+	for _, expense := range expenses {
+		if payment, ok := byExpense[expense.UUID]; ok {
+			result.Linked = append(result.Linked, ReconciledPayment{Expense: expense, Payment: payment})
+		} else {
+			result.UnmatchedExpenses = append(result.UnmatchedExpenses, expense)
+		}
+	}
+
+	// A statement is only expected to be paid once its due date (or the payment window) is over
+	for _, resume := range resumes {
+		if paidResumes[resume.DocumentNumber] || resume.TotalARS <= 0 {
+			continue
+		}
+		deadline, ok := StatementClosingDate(resume)
+		if !ok {
+			continue
+		}
+		deadline = deadline.AddDate(0, 0, cardPaymentWindowDays)
+		if due, err := time.Parse(cardPaymentDateLayout, resume.DueDate); err == nil {
+			deadline = due
+		}
+		if deadline.Before(today) {
+			result.UnmatchedStatements = append(result.UnmatchedStatements, resume)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"finance-backend/models"
+	"finance-backend/utils"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of records that splits and tags refer to
+const (
+	RecordSourceExpense = "expense" // sheet expense, keyed by UUID
+	RecordSourceIncome  = "income"  // sheet income, keyed by UUID
+	RecordSourceCard    = "card"    // card line item, keyed by CardItemKey
+)
+
+// cardItemKeySQL builds CardItemKey in queries over holder_expenses (joinedCardItemKeySQL when aliased as e)
+const (
+	cardItemKeySQL       = "document_number || '/' || holder || '/' || position"
+	joinedCardItemKeySQL = "e.document_number || '/' || e.holder || '/' || e.position"
+)
+
+// CardItemKey identifies a card line item, same key used by the anomalies
+func CardItemKey(documentNumber string, holder string, position int) string {
+	return fmt.Sprintf("%s/%s/%d", documentNumber, holder, position)
+}
+
+// ValidRecordSource tells whether source is expense, income or card
+func ValidRecordSource(source string) bool {
+	return source == RecordSourceExpense || source == RecordSourceIncome || source == RecordSourceCard
+}
+
+// ExistingRecordKeys returns which of the keys exist for the source
+func ExistingRecordKeys(transactionsDB *gorm.DB, cardsDB *gorm.DB, source string, keys []string) ([]string, error) {
+
+	existing := []string{}
+	if len(keys) == 0 {
+		return existing, nil
+	}
+
+	var err error
+	switch source {
+	case RecordSourceExpense:
+		err = transactionsDB.Model(&models.Expenses{}).Where("uuid IN ?", keys).Pluck("uuid", &existing).Error
+	case RecordSourceIncome:
+		err = transactionsDB.Model(&models.Incomes{}).Where("uuid IN ?", keys).Pluck("uuid", &existing).Error
+	case RecordSourceCard:
+		err = cardsDB.Model(&models.HolderExpense{}).Where(cardItemKeySQL+" IN ?", keys).Pluck(cardItemKeySQL, &existing).Error
+	default:
+		return nil, fmt.Errorf("invalid source %q, expected expense, income or card", source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching records at ExistingRecordKeys(): %w", err)
+	}
+	return existing, nil
+}
+
+/*
+SearchRecordKeys returns the keys of the records of a source whose description contains query (case insensitive)
+- from / to: optional date range (zero to leave it open), card line items use the statement date
+*/
+func SearchRecordKeys(transactionsDB *gorm.DB, cardsDB *gorm.DB, source string, query string, from time.Time, to time.Time) ([]string, error) {
+
+	pattern := "%" + strings.ToLower(strings.TrimSpace(query)) + "%"
+	keys := []string{}
+
+	switch source {
+	case RecordSourceExpense:
+		search := transactionsDB.Model(&models.Expenses{}).Where("LOWER(description) LIKE ?", pattern)
+		if !from.IsZero() {
+			search = search.Where("strftime('%Y-%m-%d', date) >= ?", from.Format("2006-01-02"))
+		}
+		if !to.IsZero() {
+			search = search.Where("strftime('%Y-%m-%d', date) <= ?", to.Format("2006-01-02"))
+		}
+		if err := search.Pluck("uuid", &keys).Error; err != nil {
+			return nil, fmt.Errorf("error searching expenses at SearchRecordKeys(): %w", err)
+		}
+
+	case RecordSourceIncome:
+		// date_time is stored in several layouts, so the range is applied after parsing it
+		var incomes []models.Incomes
+		if err := transactionsDB.Select("uuid, date_time").Where("LOWER(description) LIKE ?", pattern).Find(&incomes).Error; err != nil {
+			return nil, fmt.Errorf("error searching incomes at SearchRecordKeys(): %w", err)
+		}
+		for _, income := range incomes {
+			if !from.IsZero() || !to.IsZero() {
+				date, err := utils.ParseTransactionDate(income.DateTime)
+				if err != nil || (!from.IsZero() && date.Before(from)) || (!to.IsZero() && !date.Before(to.AddDate(0, 0, 1))) {
+					continue
+				}
+			}
+			keys = append(keys, income.UUID)
+		}
+
+	case RecordSourceCard:
+		search := cardsDB.Table("holder_expenses AS e").
+			Joins("JOIN resumes r ON e.document_number = r.document_number").
+			Where("LOWER(e.description) LIKE ?", pattern)
+		if !from.IsZero() {
+			search = search.Where("strftime('%Y-%m-%d', r.resume_date) >= ?", from.Format("2006-01-02"))
+		}
+		if !to.IsZero() {
+			search = search.Where("strftime('%Y-%m-%d', r.resume_date) <= ?", to.Format("2006-01-02"))
+		}
+		if err := search.Pluck(joinedCardItemKeySQL, &keys).Error; err != nil {
+			return nil, fmt.Errorf("error searching card expenses at SearchRecordKeys(): %w", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid source %q, expected expense, income or card", source)
+	}
+
+	return keys, nil
+}
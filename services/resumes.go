@@ -0,0 +1,157 @@
+package services
+
+import (
+	"finance-backend/models"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// replacedKeyPrefix marks the references being moved by ReplaceResume, so swapping two keys never hits the unique indexes
+const replacedKeyPrefix = "replacing:"
+
+/*
+ReplaceResume stores a new version of an already imported statement (same card and period).
+The resume keeps its document number so references to it stay valid, its holders and
+line items are replaced, and the installment plans are rebuilt from the stored line items.
+The tags, splits, anomalies and attachments of a line item follow it to its new key when the
+new version has an item with the same date, description and amount. The tags, splits and
+anomalies of the items missing from the new version are deleted.
+Returns the keys of the missing items, their attachments are left for the caller (they have files).
+*/
+func ReplaceResume(db *gorm.DB, existing models.Resume, resume models.Resume) (models.Resume, []string, error) {
+
+	resume.DocumentNumber = existing.DocumentNumber
+	resume.Version = existing.Version + 1
+	if existing.Version == 0 {
+		resume.Version = 2 // imported before versions were tracked
+	}
+	for i := range resume.Holders {
+		resume.Holders[i].DocumentNumber = existing.DocumentNumber
+		for j := range resume.Holders[i].Expenses {
+			resume.Holders[i].Expenses[j].DocumentNumber = existing.DocumentNumber
+		}
+	}
+
+	var dropped []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var previous []models.HolderExpense
+		if err := tx.Where("document_number = ?", existing.DocumentNumber).Order("holder ASC, position ASC").Find(&previous).Error; err != nil {
+			return fmt.Errorf("error fetching line items: %w", err)
+		}
+
+		var moved map[string]string
+		moved, dropped = matchReplacedItems(previous, resume.Holders)
+		if err := moveCardReferences(tx, moved, dropped); err != nil {
+			return err
+		}
+
+		if err := tx.Where("document_number = ?", existing.DocumentNumber).Delete(&models.HolderExpense{}).Error; err != nil {
+			return fmt.Errorf("error deleting line items: %w", err)
+		}
+		if err := tx.Where("document_number = ?", existing.DocumentNumber).Delete(&models.Holder{}).Error; err != nil {
+			return fmt.Errorf("error deleting holders: %w", err)
+		}
+		if err := tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&resume).Error; err != nil {
+			return fmt.Errorf("error saving resume: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return resume, nil, fmt.Errorf("error at ReplaceResume(): %w", err)
+	}
+
+	if _, err := RebuildInstallmentPlans(db); err != nil {
+		return resume, dropped, err
+	}
+	return resume, dropped, nil
+}
+
+/*
+matchReplacedItems pairs the line items of two versions of a statement by date, description and amount,
+in order when several items are equal. Returns the old key -> new key of the items whose key changed
+and the keys of the old items without a pair.
+*/
+func matchReplacedItems(previous []models.HolderExpense, holders []models.Holder) (map[string]string, []string) {
+
+	itemKey := func(item models.HolderExpense) string {
+		date := item.Date
+		if len(date) > 10 {
+			date = date[:10] // stored dates can come back as "2006-01-02T15:04:05Z"
+		}
+		return fmt.Sprintf("%s|%s|%.2f", date, strings.Join(strings.Fields(item.Description), " "), item.Amount)
+	}
+
+	available := make(map[string][]string)
+	for _, item := range previous {
+		available[itemKey(item)] = append(available[itemKey(item)], CardItemKey(item.DocumentNumber, item.Holder, item.Position))
+	}
+
+	moved := make(map[string]string)
+	for _, holder := range holders {
+		for _, item := range holder.Expenses {
+			keys := available[itemKey(item)]
+			if len(keys) == 0 {
+				continue
+			}
+			available[itemKey(item)] = keys[1:]
+			if newKey := CardItemKey(item.DocumentNumber, item.Holder, item.Position); newKey != keys[0] {
+				moved[keys[0]] = newKey
+			}
+		}
+	}
+
+	var dropped []string
+	for _, item := range previous {
+		for _, key := range available[itemKey(item)] {
+			dropped = append(dropped, key)
+		}
+		delete(available, itemKey(item))
+	}
+	return moved, dropped
+}
+
+// moveCardReferences renames the card line item keys of the tags, splits, anomalies and attachments, and deletes the dropped ones but the attachments
+func moveCardReferences(tx *gorm.DB, moved map[string]string, dropped []string) error {
+
+	if len(dropped) > 0 {
+		var splitIDs []uint
+		if err := tx.Model(&models.ExpenseSplit{}).Where("source = ? AND record_key IN ?", RecordSourceCard, dropped).Pluck("id", &splitIDs).Error; err != nil {
+			return fmt.Errorf("error fetching splits: %w", err)
+		}
+		if len(splitIDs) > 0 {
+			if err := tx.Where("split_id IN ?", splitIDs).Delete(&models.ExpenseSplitShare{}).Error; err != nil {
+				return fmt.Errorf("error deleting split shares: %w", err)
+			}
+			if err := tx.Where("id IN ?", splitIDs).Delete(&models.ExpenseSplit{}).Error; err != nil {
+				return fmt.Errorf("error deleting splits: %w", err)
+			}
+		}
+		if err := tx.Where("source = ? AND record_key IN ?", RecordSourceCard, dropped).Delete(&models.TagLink{}).Error; err != nil {
+			return fmt.Errorf("error deleting tag links: %w", err)
+		}
+		if err := tx.Where("source = ? AND (record_key IN ? OR related_key IN ?)", RecordSourceCard, dropped, dropped).Delete(&models.Anomaly{}).Error; err != nil {
+			return fmt.Errorf("error deleting anomalies: %w", err)
+		}
+	}
+
+	// Every moved key is renamed to a temporary one first, then to its new key
+	for _, step := range []func(string, string) (string, string){
+		func(oldKey string, newKey string) (string, string) { return oldKey, replacedKeyPrefix + newKey },
+		func(oldKey string, newKey string) (string, string) { return replacedKeyPrefix + newKey, newKey },
+	} {
+		for oldKey, newKey := range moved {
+			from, to := step(oldKey, newKey)
+			for _, model := range []interface{}{&models.TagLink{}, &models.ExpenseSplit{}, &models.Anomaly{}, &models.Attachment{}} {
+				if err := tx.Model(model).Where("source = ? AND record_key = ?", RecordSourceCard, from).Update("record_key", to).Error; err != nil {
+					return fmt.Errorf("error moving references of %s: %w", oldKey, err)
+				}
+			}
+			if err := tx.Model(&models.Anomaly{}).Where("source = ? AND related_key = ?", RecordSourceCard, from).Update("related_key", to).Error; err != nil {
+				return fmt.Errorf("error moving anomalies related to %s: %w", oldKey, err)
+			}
+		}
+	}
+	return nil
+}
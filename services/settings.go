@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"finance-backend/config"
+	"finance-backend/models"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidSetting = errors.New("invalid setting")
+
+// LoadStoredSettings applies the settings stored in the database, the shared ones and the ones of each household
+func LoadStoredSettings(db *gorm.DB) error {
+
+	var settings []models.Setting
+	if err := db.Find(&settings).Error; err != nil {
+		return fmt.Errorf("error fetching settings at LoadStoredSettings(): %w", err)
+	}
+
+	values := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		if setting.Value != "" { // stored before empty values were rejected, the lower layers apply
+			values[setting.Key] = setting.Value
+		}
+	}
+	if err := config.SetDatabaseValues(values); err != nil {
+		return err
+	}
+
+	var tenantSettings []models.TenantSetting
+	if err := db.Find(&tenantSettings).Error; err != nil {
+		return fmt.Errorf("error fetching household settings at LoadStoredSettings(): %w", err)
+	}
+
+	tenantValues := make(map[uint]map[string]string)
+	for _, setting := range tenantSettings {
+		if tenantValues[setting.TenantID] == nil {
+			tenantValues[setting.TenantID] = make(map[string]string)
+		}
+		tenantValues[setting.TenantID][setting.Key] = setting.Value
+	}
+	return config.SetTenantValues(tenantValues)
+}
+
+// TenantSetting returns the value of a setting for the household, see config.TenantString
+func TenantSetting(tenant models.Tenant, key string) string {
+	return config.TenantString(tenant.ID, key)
+}
+
+/*
+SettingValues lists the settings the admins of the household can see: every setting that is not
+secret for the default household, only the ones they can override (config.Setting.Household) for the others
+*/
+func SettingValues(tenant models.Tenant) []config.SettingValue {
+	if IsDefaultTenant(tenant) {
+		return config.Values()
+	}
+	return config.TenantValues(tenant.ID)
+}
+
+/*
+SaveSetting stores a runtime setting and applies it right away. The default household changes
+the value shared by every household, the others only override the Household settings for themselves.
+- returns ErrInvalidSetting for unknown, secret or startup settings, shared ones and invalid values
+*/
+func SaveSetting(db *gorm.DB, tenant models.Tenant, key string, value string) (interface{}, error) {
+
+	if IsDefaultTenant(tenant) {
+		setting := models.Setting{Key: key, Value: value}
+		if err := config.ValidateRuntimeValue(key, value); err != nil {
+			return setting, fmt.Errorf("%w: %v", ErrInvalidSetting, err)
+		}
+		if err := db.Save(&setting).Error; err != nil {
+			return setting, fmt.Errorf("error storing setting at SaveSetting(): %w", err)
+		}
+		return setting, LoadStoredSettings(db)
+	}
+
+	setting := models.TenantSetting{TenantID: tenant.ID, Key: key, Value: value}
+	if err := config.ValidateTenantValue(key, value); err != nil {
+		return setting, fmt.Errorf("%w: %v", ErrInvalidSetting, err)
+	}
+	if err := db.Save(&setting).Error; err != nil {
+		return setting, fmt.Errorf("error storing household setting at SaveSetting(): %w", err)
+	}
+	return setting, LoadStoredSettings(db)
+}
+
+// DeleteSetting removes a setting stored by the household so the shared value applies again, returns whether it existed
+func DeleteSetting(db *gorm.DB, tenant models.Tenant, key string) (bool, error) {
+
+	var result *gorm.DB
+	if IsDefaultTenant(tenant) {
+		result = db.Where("key = ?", key).Delete(&models.Setting{})
+	} else {
+		result = db.Where("tenant_id = ? AND key = ?", tenant.ID, key).Delete(&models.TenantSetting{})
+	}
+	if result.Error != nil {
+		return false, fmt.Errorf("error deleting setting at DeleteSetting(): %w", result.Error)
+	}
+	return result.RowsAffected > 0, LoadStoredSettings(db)
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+	"finance-backend/models"
+	"fmt"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrSplitRecordNotFound = errors.New("record to split not found")
+
+// SplitRecordAmount returns the amount of the sheet expense (UUID) or card line item (CardItemKey) to split
+func SplitRecordAmount(transactionsDB *gorm.DB, cardsDB *gorm.DB, source string, key string) (float64, error) {
+
+	switch source {
+	case RecordSourceExpense:
+		var expenses []models.Expenses
+		if err := transactionsDB.Where("uuid = ?", key).Limit(1).Find(&expenses).Error; err != nil {
+			return 0, fmt.Errorf("error fetching expense at SplitRecordAmount(): %w", err)
+		}
+		if len(expenses) == 0 {
+			return 0, ErrSplitRecordNotFound
+		}
+		return expenses[0].Amount, nil
+
+	case RecordSourceCard:
+		var items []models.HolderExpense
+		if err := cardsDB.Where(cardItemKeySQL+" = ?", key).Limit(1).Find(&items).Error; err != nil {
+			return 0, fmt.Errorf("error fetching card expense at SplitRecordAmount(): %w", err)
+		}
+		if len(items) == 0 {
+			return 0, ErrSplitRecordNotFound
+		}
+		return items[0].Amount, nil
+	}
+
+	return 0, fmt.Errorf("invalid source %q, expected expense or card", source)
+}
+
+/*
+BuildSplitShares validates a split of split.Amount and fills in the share amounts and percentages
+- equal: every member pays the same, the cents left go to the first member
+- percentage: the percentages add up to 100
+- fixed: the amounts add up to the amount of the record
+*/
+func BuildSplitShares(split *models.ExpenseSplit) error {
+
+	split.Method = strings.ToLower(strings.TrimSpace(split.Method))
+	if len(split.Shares) == 0 {
+		return errors.New("shares are required")
+	}
+	if split.Amount == 0 {
+		return errors.New("cannot split a record without amount")
+	}
+
+	seen := make(map[string]bool)
+	for i := range split.Shares {
+		split.Shares[i].Member = strings.TrimSpace(split.Shares[i].Member)
+		member := normalizeMember(split.Shares[i].Member)
+		if member == "" {
+			return errors.New("member is required on every share")
+		}
+		if seen[member] {
+			return fmt.Errorf("member %q appears more than once", split.Shares[i].Member)
+		}
+		seen[member] = true
+	}
+
+	switch split.Method {
+	case "equal":
+		each := roundCents(split.Amount / float64(len(split.Shares)))
+		for i := range split.Shares {
+			split.Shares[i].Amount = each
+		}
+		split.Shares[0].Amount = roundCents(split.Amount - each*float64(len(split.Shares)-1))
+
+	case "percentage":
+		total := 0.0
+		for i := range split.Shares {
+			if split.Shares[i].Percentage <= 0 {
+				return fmt.Errorf("percentage of %q must be positive", split.Shares[i].Member)
+			}
+			total += split.Shares[i].Percentage
+			split.Shares[i].Amount = roundCents(split.Amount * split.Shares[i].Percentage / 100)
+		}
+		if math.Abs(total-100) > 0.01 {
+			return fmt.Errorf("percentages add up to %.2f, expected 100", total)
+		}
+
+	case "fixed":
+		total := 0.0
+		for i := range split.Shares {
+			total += split.Shares[i].Amount
+		}
+		if math.Abs(total-split.Amount) > 0.01 {
+			return fmt.Errorf("amounts add up to %.2f, expected %.2f", total, split.Amount)
+		}
+
+	default:
+		return errors.New("invalid method, expected equal, percentage or fixed")
+	}
+
+	for i := range split.Shares {
+		split.Shares[i].Percentage = split.Shares[i].Amount / split.Amount * 100
+	}
+	return nil
+}
+
+// SaveExpenseSplit stores a split, replacing the previous split of the same record
+func SaveExpenseSplit(db *gorm.DB, split models.ExpenseSplit) (models.ExpenseSplit, error) {
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing models.ExpenseSplit
+		if err := tx.Where("source = ? AND record_key = ?", split.Source, split.RecordKey).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if existing.ID != 0 {
+			if err := tx.Where("split_id = ?", existing.ID).Delete(&models.ExpenseSplitShare{}).Error; err != nil {
+				return err
+			}
+			split.ID = existing.ID
+			split.CreatedAt = existing.CreatedAt
+		}
+		for i := range split.Shares {
+			split.Shares[i].ID = 0
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&split).Error
+	})
+	if err != nil {
+		return split, fmt.Errorf("error saving split at SaveExpenseSplit(): %w", err)
+	}
+	return split, nil
+}
+
+// LoadExpenseSplits returns the splits of a source keyed by record
+func LoadExpenseSplits(db *gorm.DB, source string) (map[string]models.ExpenseSplit, error) {
+	var splits []models.ExpenseSplit
+	if err := db.Preload("Shares").Where("source = ?", source).Find(&splits).Error; err != nil {
+		return nil, fmt.Errorf("error fetching splits at LoadExpenseSplits(): %w", err)
+	}
+	byRecord := make(map[string]models.ExpenseSplit, len(splits))
+	for _, split := range splits {
+		byRecord[split.RecordKey] = split
+	}
+	return byRecord, nil
+}
+
+/*
+MemberShare returns the part of amount owed by a member of the split, false when they are not part of it.
+Equal and percentage splits follow the current amount of the record, fixed splits keep their amounts.
+*/
+func MemberShare(split models.ExpenseSplit, member string, amount float64) (float64, bool) {
+	for _, share := range split.Shares {
+		if normalizeMember(share.Member) != normalizeMember(member) {
+			continue
+		}
+		if split.Method == "fixed" {
+			return share.Amount, true
+		}
+		return amount * share.Percentage / 100, true
+	}
+	return 0, false
+}
+
+/*
+SplitAdjustments returns, per month ("2025-07"), how much of the split sheet expenses is not
+owed by member: the raw amounts minus the member share, to subtract from the expense totals.
+Transfers (reconciled card payments) are skipped, the expense totals do not count them.
+*/
+func SplitAdjustments(transactionsDB *gorm.DB, cardsDB *gorm.DB, member string) (map[string]float64, error) {
+
+	splits, err := LoadExpenseSplits(cardsDB, RecordSourceExpense)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustments := make(map[string]float64)
+	if len(splits) == 0 {
+		return adjustments, nil
+	}
+
+	uuids := make([]string, 0, len(splits))
+	for uuid := range splits {
+		uuids = append(uuids, uuid)
+	}
+
+	var expenses []models.Expenses
+	if err := transactionsDB.Where("uuid IN ? AND transfer = ?", uuids, false).Find(&expenses).Error; err != nil {
+		return nil, fmt.Errorf("error fetching split expenses at SplitAdjustments(): %w", err)
+	}
+
+	for _, expense := range expenses {
+		share, _ := MemberShare(splits[expense.UUID], member, expense.Amount)
+		adjustments[expense.Date.Format("2006-01")] += expense.Amount - share
+	}
+	return adjustments, nil
+}
+
+/*
+CardSplitAdjustments returns, per statement month ("2025-07"), how much of the split card line
+items is not owed by member, to subtract from the card statement totals (see SplitAdjustments)
+*/
+func CardSplitAdjustments(cardsDB *gorm.DB, member string) (map[string]float64, error) {
+
+	splits, err := LoadExpenseSplits(cardsDB, RecordSourceCard)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustments := make(map[string]float64)
+	if len(splits) == 0 {
+		return adjustments, nil
+	}
+
+	keys := make([]string, 0, len(splits))
+	for key := range splits {
+		keys = append(keys, key)
+	}
+
+	var items []struct {
+		Key    string
+		Month  string
+		Amount float64
+	}
+	if err := cardsDB.Table("holder_expenses AS e").
+		Select(joinedCardItemKeySQL+" AS key, strftime('%Y-%m', r.resume_date) AS month, e.amount AS amount").
+		Joins("JOIN resumes r ON e.document_number = r.document_number").
+		Where(joinedCardItemKeySQL+" IN ?", keys).
+		Scan(&items).Error; err != nil {
+		return nil, fmt.Errorf("error fetching split card expenses at CardSplitAdjustments(): %w", err)
+	}
+
+	for _, item := range items {
+		share, _ := MemberShare(splits[item.Key], member, item.Amount)
+		adjustments[item.Month] += item.Amount - share
+	}
+	return adjustments, nil
+}
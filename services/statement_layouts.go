@@ -0,0 +1,230 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const statementAmount = `-?\d{1,3}(?:\.\d{3})*,\d{2}-?` // "1.234,56", negatives as "-1.234,56" or "1.234,56-"
+
+/*
+statementLayout describes the text lines of an issuer statement once extracted from the PDF
+- Expense: date, description and amount (the last amount of the line)
+- HolderTotal: holder name, pesos and optional dollars, closes the line items read so far
+- StatementTotal: pesos and optional dollars of the whole statement
+- ClosingDate / DueDate: the date of the statement closing and payment due
+- MinimumPayment / PreviousBalance: pesos amounts of the statement header
+- Skip: lines shaped like line items that are not consumptions (payments, previous balance)
+*/
+type statementLayout struct {
+	Issuer          string
+	Expense         *regexp.Regexp
+	DateLayouts     []string
+	HolderTotal     *regexp.Regexp
+	StatementTotal  *regexp.Regexp
+	ClosingDate     *regexp.Regexp
+	DueDate         *regexp.Regexp
+	MinimumPayment  *regexp.Regexp
+	PreviousBalance *regexp.Regexp
+	Skip            *regexp.Regexp
+}
+
+var (
+	statementDate        = `\d{2}[-/ ](?:\d{2}|[A-Za-zñÑ]{3,10}\.?)[-/ ]\d{2}(?:\d{2})?`
+	minimumPaymentLine   = regexp.MustCompile(`(?i)\bPAGO M[IÍ]NIMO:?\s+\$?\s*(` + statementAmount + `)`)
+	previousBalanceLine  = regexp.MustCompile(`(?i)\bSALDO ANTERIOR:?\s+\$?\s*(` + statementAmount + `)`)
+	statementDateLayouts = []string{"02-01-06", "02-01-2006", "02/01/06", "02/01/2006", "02-Jan-06", "02 Jan 06", "02-Jan-2006", "02 Jan 2006"}
+
+	bbvaLayout = statementLayout{
+		Issuer:          "bbva",
+		Expense:         regexp.MustCompile(`^(\d{2}-[A-Za-z]{3}-\d{2})\s+(.+?)\s+(` + statementAmount + `)$`),
+		DateLayouts:     statementDateLayouts,
+		HolderTotal:     regexp.MustCompile(`(?i)^TOTAL CONSUMOS DE\s+(.+?)\s+(` + statementAmount + `)(?:\s+(` + statementAmount + `))?$`),
+		StatementTotal:  regexp.MustCompile(`(?i)^SALDO ACTUAL\s+\$?\s*(` + statementAmount + `)(?:\s+U\$S\s*(` + statementAmount + `))?`),
+		ClosingDate:     regexp.MustCompile(`(?i)\bCIERRE ACTUAL:?\s+(` + statementDate + `)`),
+		DueDate:         regexp.MustCompile(`(?i)\bVENCIMIENTO ACTUAL:?\s+(` + statementDate + `)`),
+		MinimumPayment:  minimumPaymentLine,
+		PreviousBalance: previousBalanceLine,
+		Skip:            regexp.MustCompile(`(?i)\bSU PAGO\b|\bSALDO ANTERIOR\b`),
+	}
+
+	galiciaLayout = statementLayout{
+		Issuer:          "galicia",
+		Expense:         regexp.MustCompile(`^(\d{2}-\d{2}-\d{2})\s+(?:\*\s+)?(.+?)\s+(` + statementAmount + `)$`),
+		DateLayouts:     statementDateLayouts,
+		HolderTotal:     regexp.MustCompile(`(?i)^TOTAL CONSUMOS DE\s+(.+?)\s+(` + statementAmount + `)(?:\s+(` + statementAmount + `))?$`),
+		StatementTotal:  regexp.MustCompile(`(?i)^SALDO ACTUAL\s+\$?\s*(` + statementAmount + `)(?:\s+U\$S\s*(` + statementAmount + `))?`),
+		ClosingDate:     regexp.MustCompile(`(?i)\bCIERRE ACTUAL:?\s+(` + statementDate + `)`),
+		DueDate:         regexp.MustCompile(`(?i)\bVENCIMIENTO ACTUAL:?\s+(` + statementDate + `)`),
+		MinimumPayment:  minimumPaymentLine,
+		PreviousBalance: previousBalanceLine,
+		Skip:            regexp.MustCompile(`(?i)\bSU PAGO\b|\bSALDO ANTERIOR\b`),
+	}
+
+	santanderLayout = statementLayout{
+		Issuer:          "santander",
+		Expense:         regexp.MustCompile(`^(\d{2}/\d{2}/\d{2}(?:\d{2})?)\s+(?:\d{6}\s+)?(?:[*K]\s+)?(.+?)\s+(` + statementAmount + `)$`),
+		DateLayouts:     statementDateLayouts,
+		HolderTotal:     regexp.MustCompile(`(?i)^(?:TARJETA \d+\s+)?TOTAL CONSUMOS DE\s+(.+?)\s+(` + statementAmount + `)(?:\s+(` + statementAmount + `))?$`),
+		StatementTotal:  regexp.MustCompile(`(?i)^SALDO ACTUAL\s+\$?\s*(` + statementAmount + `)(?:\s+U\$S\s*(` + statementAmount + `))?`),
+		ClosingDate:     regexp.MustCompile(`(?i)\bCIERRE:?\s+(` + statementDate + `)`),
+		DueDate:         regexp.MustCompile(`(?i)\bVENCIMIENTO:?\s+(` + statementDate + `)`),
+		MinimumPayment:  minimumPaymentLine,
+		PreviousBalance: previousBalanceLine,
+		Skip:            regexp.MustCompile(`(?i)\bSU PAGO\b|\bSALDO ANTERIOR\b|\bPAGO EN PESOS\b`),
+	}
+
+	spanishMonths = strings.NewReplacer(
+		"Enero", "Jan", "Febrero", "Feb", "Marzo", "Mar", "Abril", "Apr", "Mayo", "May", "Junio", "Jun",
+		"Julio", "Jul", "Agosto", "Aug", "Septiembre", "Sep", "Setiembre", "Sep", "Octubre", "Oct",
+		"Noviembre", "Nov", "Diciembre", "Dec",
+		"Ene", "Jan", "Abr", "Apr", "Ago", "Aug", "Set", "Sep", "Dic", "Dec",
+	)
+)
+
+// textStatementParser parses the statements of an issuer from the PDF text, in process
+type textStatementParser struct {
+	layout statementLayout
+}
+
+func (parser *textStatementParser) ReadResumes(path ResumePath) (*Statement, error) {
+
+	if path.FilePath == "" {
+		return nil, fmt.Errorf("file path is empty")
+	}
+
+	data, err := os.ReadFile(path.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	lines, err := ExtractPDFText(data)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path.FileName, err)
+	}
+
+	statement, err := parseStatementText(lines, parser.layout)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path.FileName, err)
+	}
+
+	hash, err := hashString(data)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing file: %w", err)
+	}
+	statement.Hash = hash
+
+	return statement, nil
+}
+
+/*
+parseStatementText builds a statement from its text lines. Line items belong to the
+holder whose total line follows them, items after the last holder total (taxes and
+card charges) are added to the first holder.
+*/
+func parseStatementText(lines []string, layout statementLayout) (*Statement, error) {
+
+	statement := &Statement{}
+	var pending []Expense
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+
+		if statement.ClosingDate.IsZero() {
+			if match := layout.ClosingDate.FindStringSubmatch(line); match != nil {
+				statement.ClosingDate = parseStatementDate(match[1], layout.DateLayouts)
+			}
+		}
+		if statement.DueDate.IsZero() {
+			if match := layout.DueDate.FindStringSubmatch(line); match != nil {
+				statement.DueDate = parseStatementDate(match[1], layout.DateLayouts)
+			}
+		}
+
+		if match := layout.MinimumPayment.FindStringSubmatch(line); match != nil && statement.MinimumPayment == 0 {
+			statement.MinimumPayment, _ = parseStatementAmount(match[1])
+		}
+		if match := layout.PreviousBalance.FindStringSubmatch(line); match != nil && statement.PreviousBalance == 0 {
+			statement.PreviousBalance, _ = parseStatementAmount(match[1])
+		}
+
+		if match := layout.HolderTotal.FindStringSubmatch(line); match != nil {
+			pesos, _ := parseStatementAmount(match[2])
+			dollars, _ := parseStatementAmount(match[3])
+			statement.Holders = append(statement.Holders, Holders{
+				Holder:   strings.TrimSpace(match[1]),
+				Expenses: pending,
+				Totals:   Totals{ARS: pesos, USD: dollars},
+			})
+			pending = nil
+			continue
+		}
+
+		if match := layout.StatementTotal.FindStringSubmatch(line); match != nil {
+			pesos, _ := parseStatementAmount(match[1])
+			dollars, _ := parseStatementAmount(match[2])
+			statement.Totals = Totals{ARS: pesos, USD: dollars}
+			continue
+		}
+
+		if layout.Skip != nil && layout.Skip.MatchString(line) {
+			continue
+		}
+
+		match := layout.Expense.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		amount, err := parseStatementAmount(match[3])
+		if err != nil {
+			continue
+		}
+		pending = append(pending, Expense{
+			Date:        parseStatementDate(match[1], layout.DateLayouts),
+			Description: strings.Join(strings.Fields(match[2]), " "),
+			Amount:      amount,
+		})
+	}
+
+	if len(pending) > 0 {
+		if len(statement.Holders) == 0 {
+			statement.Holders = append(statement.Holders, Holders{Holder: "TITULAR"})
+		}
+		statement.Holders[0].Expenses = append(statement.Holders[0].Expenses, pending...)
+	}
+
+	items := 0
+	for _, holder := range statement.Holders {
+		items += len(holder.Expenses)
+	}
+	if items == 0 {
+		return nil, fmt.Errorf("no line items found, is this a %s statement?", layout.Issuer)
+	}
+
+	return statement, nil
+}
+
+func parseStatementAmount(input string) (float64, error) {
+	input = strings.TrimSpace(input)
+	negative := strings.HasSuffix(input, "-")
+	amount, err := parseAmount(strings.TrimSuffix(input, "-"))
+	if negative {
+		amount = -amount
+	}
+	return amount, err
+}
+
+// parseStatementDate parses numeric and Spanish month dates ("05-Ago-25", "05 Agosto 25"), zero if none matches
+func parseStatementDate(input string, layouts []string) time.Time {
+	normalized := spanishMonths.Replace(strings.TrimSuffix(strings.TrimSpace(input), "."))
+	normalized = strings.ReplaceAll(normalized, ".", "")
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, normalized); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"finance-backend/models"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Statement is a card statement already parsed by a StatementParser
+type Statement struct {
+	Holders         []Holders
+	Totals          Totals
+	Hash            string    // SHA-256 of the PDF file
+	ClosingDate     time.Time // zero when the format does not expose it
+	DueDate         time.Time
+	MinimumPayment  float64 // pesos, zero when the format does not expose it
+	PreviousBalance float64
+}
+
+// StatementParser reads the card statement PDFs of one issuer
+type StatementParser interface {
+	ReadResumes(path ResumePath) (*Statement, error)
+}
+
+// statementParsers build the parser of an issuer for a household, some read settings the household can override
+var statementParsers = map[string]func(tenant models.Tenant) (StatementParser, error){
+	"bbva": func(tenant models.Tenant) (StatementParser, error) { return NewPdfReaderBBVA(tenant) },
+	"galicia": func(tenant models.Tenant) (StatementParser, error) {
+		return &textStatementParser{layout: galiciaLayout}, nil
+	},
+	"santander": func(tenant models.Tenant) (StatementParser, error) {
+		return &textStatementParser{layout: santanderLayout}, nil
+	},
+}
+
+// RegisterStatementParser adds (or replaces) the parser used for an issuer
+func RegisterStatementParser(issuer string, factory func(tenant models.Tenant) (StatementParser, error)) {
+	statementParsers[strings.ToLower(issuer)] = factory
+}
+
+// NewStatementParser returns the parser registered for an issuer (bbva, galicia, santander) for the household
+func NewStatementParser(tenant models.Tenant, issuer string) (StatementParser, error) {
+	factory, ok := statementParsers[strings.ToLower(strings.TrimSpace(issuer))]
+	if !ok {
+		return nil, fmt.Errorf("no statement parser for issuer %q, expected one of %s", issuer, strings.Join(StatementIssuers(), ", "))
+	}
+	return factory(tenant)
+}
+
+// StatementIssuers lists the issuers with a registered parser
+func StatementIssuers() []string {
+	issuers := make([]string, 0, len(statementParsers))
+	for issuer := range statementParsers {
+		issuers = append(issuers, issuer)
+	}
+	sort.Strings(issuers)
+	return issuers
+}
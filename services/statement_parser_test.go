@@ -0,0 +1,85 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"finance-backend/models"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of testdata/statements")
+
+/*
+The fixtures are small synthetic PDFs that follow the text layout of each issuer, not real statements,
+so they pin the parsing rules but do not prove a real statement of the issuer is read. The golden files
+hold what the native parser reads from them. After a parser change, check the diff of
+go test ./services -run TestStatementParsersGolden -update
+*/
+func TestStatementParsersGolden(t *testing.T) {
+	for _, issuer := range StatementIssuers() {
+		t.Run(issuer, func(t *testing.T) {
+			parser, err := NewStatementParser(models.Tenant{}, issuer)
+			if err != nil {
+				t.Fatalf("NewStatementParser(%q) error = %v", issuer, err)
+			}
+
+			path := filepath.Join("testdata", "statements", issuer+".pdf")
+			statement, err := parser.ReadResumes(ResumePath{FilePath: path, FileName: issuer + ".pdf"})
+			if err != nil {
+				t.Fatalf("ReadResumes(%s) error = %v", path, err)
+			}
+
+			got, err := json.MarshalIndent(statement, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", "statements", issuer+".golden.json")
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("missing golden file, run with -update: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("ReadResumes(%s) differs from %s:\n%s", path, golden, got)
+			}
+		})
+	}
+}
+
+func TestBBVAReaderFallsBackToService(t *testing.T) {
+	called := 0
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+		w.Write([]byte(`{"SERVICIO": {"Detail": [{"fecha": "03-Ago-25", "descripcion": "DESDE EL SERVICIO", "importe": "1,00"}], "Total": {"pesos": "1,00", "dolares": "0,00"}}}`))
+	}))
+	defer service.Close()
+
+	reader := &PdfReaderBBVA{service: service.URL, native: &textStatementParser{layout: bbvaLayout}}
+
+	path := ResumePath{FilePath: filepath.Join("testdata", "statements", "bbva.pdf"), FileName: "bbva.pdf"}
+	statement, err := reader.ReadResumes(path)
+	if err != nil || len(statement.Holders) != 2 || statement.DueDate.IsZero() || called != 0 {
+		t.Fatalf("ReadResumes() = %+v, %v (service called %d times), want the native statement", statement, err, called)
+	}
+
+	unreadable := filepath.Join(t.TempDir(), "unreadable.pdf")
+	if err := os.WriteFile(unreadable, []byte("%PDF-1.4\nnot a statement"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	statement, err = reader.ReadResumes(ResumePath{FilePath: unreadable, FileName: "unreadable.pdf"})
+	if err != nil || len(statement.Holders) != 1 || statement.Holders[0].Holder != "SERVICIO" || called != 1 {
+		t.Fatalf("ReadResumes() = %+v, %v, want the statement of the service when the native parser fails", statement, err)
+	}
+}
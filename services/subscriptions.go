@@ -0,0 +1,263 @@
+package services
+
+import (
+	"finance-backend/models"
+	"finance-backend/utils"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	subscriptionMinMonths     = 3    // consecutive statements needed to consider a charge recurring
+	subscriptionAmountDrift   = 0.30 // max change between two months to be "a similar amount" (inflation included)
+	subscriptionPriceIncrease = 0.01 // smaller increases are rounding, not a new price
+	subscriptionMissedMonths  = 2    // statements without the charge before it is considered cancelled
+)
+
+type SubscriptionDetection struct {
+	Detected  int `json:"detected"`
+	Updated   int `json:"updated"`
+	Cancelled int `json:"cancelled"`
+	Alerts    int `json:"alerts"`
+}
+
+type subscriptionCharge struct {
+	Description string
+	Amount      float64
+}
+
+/*
+DetectSubscriptions looks for recurring charges (same card, holder and merchant,
+a similar amount on consecutive monthly statements) and keeps their lifecycle:
+  - new recurring charges are stored as active subscriptions with their price history
+  - known subscriptions get the new months appended, raising a price_increase alert
+    when the amount goes up
+  - subscriptions missing from the last statements of their card are marked cancelled
+  - charges of a cancelled subscription raise a charge_after_cancel alert
+*/
+func DetectSubscriptions(db *gorm.DB) (SubscriptionDetection, error) {
+
+	var result SubscriptionDetection
+
+	var rows []struct {
+		CardType    string
+		Holder      string
+		Description string
+		Amount      float64
+		Month       string
+	}
+
+	if err := db.Table("holder_expenses AS e").
+		Select("r.card_type, e.holder, e.description, e.amount, strftime('%Y-%m', r.resume_date) AS month").
+		Joins("JOIN resumes r ON e.document_number = r.document_number").
+		Where("COALESCE(e.installment_total, 0) = 0").
+		Order("month ASC, e.position ASC").
+		Scan(&rows).Error; err != nil {
+		return result, fmt.Errorf("error fetching card expenses at DetectSubscriptions(): %w", err)
+	}
+
+	// key -> month -> first charge of the merchant on that statement
+	groups := make(map[string]map[string]subscriptionCharge)
+	identity := make(map[string][3]string)
+	latestByCard := make(map[string]string)
+
+	for _, row := range rows {
+		if row.Month > latestByCard[row.CardType] {
+			latestByCard[row.CardType] = row.Month
+		}
+		merchant := utils.NormalizeMerchant(row.Description)
+		if merchant == "" || row.Amount <= 0 {
+			continue
+		}
+		key := row.CardType + "|" + row.Holder + "|" + merchant
+		if groups[key] == nil {
+			groups[key] = make(map[string]subscriptionCharge)
+			identity[key] = [3]string{row.CardType, row.Holder, merchant}
+		}
+		if _, exists := groups[key][row.Month]; !exists {
+			groups[key][row.Month] = subscriptionCharge{Description: row.Description, Amount: row.Amount}
+		}
+	}
+
+	var existing []models.Subscription
+	if err := db.Preload("Prices").Find(&existing).Error; err != nil {
+		return result, fmt.Errorf("error fetching subscriptions at DetectSubscriptions(): %w", err)
+	}
+	known := make(map[string]*models.Subscription, len(existing))
+	for i := range existing {
+		known[existing[i].SubscriptionKey] = &existing[i]
+	}
+
+	var alerts []models.Anomaly
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		charges := groups[key]
+		months := make([]string, 0, len(charges))
+		for month := range charges {
+			months = append(months, month)
+		}
+		sort.Strings(months)
+
+		subscription, isKnown := known[key]
+
+		if !isKnown {
+			run := latestRecurringRun(months, charges)
+			if len(run) < subscriptionMinMonths {
+				continue
+			}
+
+			last := charges[run[len(run)-1]]
+			id := identity[key]
+			subscription = &models.Subscription{
+				SubscriptionKey: key,
+				CardType:        id[0],
+				Holder:          id[1],
+				Merchant:        id[2],
+				Description:     last.Description,
+				Currency:        chargeCurrency(last.Description),
+				StartMonth:      run[0],
+				LastSeenMonth:   run[len(run)-1],
+				LastAmount:      last.Amount,
+				Status:          "active",
+			}
+			for _, month := range run {
+				subscription.Prices = append(subscription.Prices, models.SubscriptionPrice{Month: month, Amount: charges[month].Amount})
+			}
+			if err := db.Create(subscription).Error; err != nil {
+				return result, fmt.Errorf("error saving subscription at DetectSubscriptions(): %w", err)
+			}
+			result.Detected++
+			continue
+		}
+
+		// Known subscription, append the statements after the last one recorded
+		changed := false
+		for _, month := range months {
+			if month <= subscription.LastSeenMonth {
+				continue
+			}
+			charge := charges[month]
+
+			if subscription.Status == "cancelled" && subscription.CancelledAt != nil && month > subscription.CancelledAt.Format("2006-01") {
+				alerts = append(alerts, subscriptionAlert("charge_after_cancel", subscription, month, charge, 1,
+					fmt.Sprintf("charged on the %s statement after being cancelled", month)))
+			}
+
+			if previous := subscription.LastAmount; previous > 0 && charge.Amount > previous*(1+subscriptionPriceIncrease) {
+				increase := (charge.Amount - previous) / previous
+				alerts = append(alerts, subscriptionAlert("price_increase", subscription, month, charge, increase,
+					fmt.Sprintf("price went from %.2f to %.2f (+%.1f%%)", previous, charge.Amount, increase*100)))
+			}
+
+			price := models.SubscriptionPrice{SubscriptionID: subscription.ID, Month: month, Amount: charge.Amount}
+			if err := db.Create(&price).Error; err != nil {
+				return result, fmt.Errorf("error saving subscription price at DetectSubscriptions(): %w", err)
+			}
+
+			subscription.LastSeenMonth = month
+			subscription.LastAmount = charge.Amount
+			subscription.Description = charge.Description
+			changed = true
+		}
+
+		if changed {
+			if err := db.Omit("Prices").Save(subscription).Error; err != nil {
+				return result, fmt.Errorf("error updating subscription at DetectSubscriptions(): %w", err)
+			}
+			result.Updated++
+		}
+	}
+
+	// Active subscriptions missing from the last statements of their card
+	if err := db.Preload("Prices").Find(&existing).Error; err != nil {
+		return result, fmt.Errorf("error fetching subscriptions at DetectSubscriptions(): %w", err)
+	}
+	for i := range existing {
+		subscription := &existing[i]
+		if subscription.Status != "active" || monthsBetween(subscription.LastSeenMonth, latestByCard[subscription.CardType]) < subscriptionMissedMonths {
+			continue
+		}
+		// Cancelled as of its last charge, any later statement charging it raises an alert
+		cancelledAt, err := time.Parse("2006-01", subscription.LastSeenMonth)
+		if err != nil {
+			continue
+		}
+		subscription.Status = "cancelled"
+		subscription.CancelledAt = &cancelledAt
+		if err := db.Omit("Prices").Save(subscription).Error; err != nil {
+			return result, fmt.Errorf("error cancelling subscription at DetectSubscriptions(): %w", err)
+		}
+		result.Cancelled++
+	}
+
+	created, err := SaveAnomalies(db, alerts)
+	if err != nil {
+		return result, err
+	}
+	result.Alerts = created
+
+	return result, nil
+}
+
+// latestRecurringRun returns the most recent run of consecutive months with similar amounts
+func latestRecurringRun(months []string, charges map[string]subscriptionCharge) []string {
+	var best, run []string
+	for _, month := range months {
+		if len(run) > 0 {
+			previous := run[len(run)-1]
+			drift := math.Abs(charges[month].Amount-charges[previous].Amount) / charges[previous].Amount
+			if monthsBetween(previous, month) != 1 || drift > subscriptionAmountDrift {
+				run = nil
+			}
+		}
+		run = append(run, month)
+		if len(run) >= subscriptionMinMonths {
+			best = run
+		}
+	}
+	return best
+}
+
+// monthsBetween returns how many months "to" is after "from" (both YYYY-MM)
+func monthsBetween(from string, to string) int {
+	fromTime, err := time.Parse("2006-01", from)
+	if err != nil {
+		return 0
+	}
+	toTime, err := time.Parse("2006-01", to)
+	if err != nil {
+		return 0
+	}
+	return (toTime.Year()-fromTime.Year())*12 + int(toTime.Month()) - int(fromTime.Month())
+}
+
+func chargeCurrency(description string) string {
+	if strings.Contains(strings.ToUpper(description), "USD") {
+		return "USD"
+	}
+	return "ARS"
+}
+
+func subscriptionAlert(kind string, subscription *models.Subscription, month string, charge subscriptionCharge, score float64, detail string) models.Anomaly {
+	return models.Anomaly{
+		Kind:        kind,
+		Source:      "subscription",
+		RecordKey:   fmt.Sprintf("%d/%s", subscription.ID, month),
+		Date:        month + "-01",
+		Description: charge.Description,
+		Amount:      charge.Amount,
+		Detail:      detail,
+		Score:       math.Round(score*100) / 100,
+	}
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"errors"
+	"finance-backend/models"
+	"finance-backend/utils"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var tagName = regexp.MustCompile(`^[\p{L}0-9][\p{L}0-9 _.-]{0,49}$`)
+
+// ValidateTag checks a tag before it is stored, names are kept lowercase
+func ValidateTag(tag *models.Tag) error {
+	tag.Name = strings.ToLower(strings.TrimSpace(tag.Name))
+	tag.Color = strings.TrimSpace(tag.Color)
+	if !tagName.MatchString(tag.Name) {
+		return errors.New("invalid name, expected up to 50 letters, digits, spaces, '.', '_' or '-'")
+	}
+	return nil
+}
+
+// LinkTag attaches a tag to the records of a source, returns how many links were created
+func LinkTag(db *gorm.DB, tagID uint, source string, keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	links := make([]models.TagLink, len(keys))
+	for i, key := range keys {
+		links[i] = models.TagLink{TagID: tagID, Source: source, RecordKey: key}
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&links, 500)
+	if result.Error != nil {
+		return 0, fmt.Errorf("error tagging records at LinkTag(): %w", result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
+
+// UnlinkTag removes a tag from the records of a source, returns how many links were removed
+func UnlinkTag(db *gorm.DB, tagID uint, source string, keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	result := db.Where("tag_id = ? AND source = ? AND record_key IN ?", tagID, source, keys).Delete(&models.TagLink{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("error untagging records at UnlinkTag(): %w", result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
+
+// AttachedTagLinks is the tag links table as seen from the transactions database, see WithCardsDatabase
+const AttachedTagLinks = "cards.tag_links"
+
+// TagFilter restricts reports to the records tagged with any of a set of tags
+type TagFilter struct {
+	Tags []string
+	ids  []uint
+}
+
+/*
+NewTagFilter reads the comma separated tags of a ?tag= param.
+Returns nil (no filtering) when the param is empty, and an error for unknown tags.
+*/
+func NewTagFilter(db *gorm.DB, param string) (*TagFilter, error) {
+
+	var names []string
+	for _, name := range strings.Split(param, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var tags []models.Tag
+	if err := db.Where("name IN ?", names).Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("error fetching tags at NewTagFilter(): %w", err)
+	}
+	found := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		found[tag.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("unknown tag %q", name)
+		}
+	}
+
+	filter := &TagFilter{Tags: names, ids: make([]uint, len(tags))}
+	for i, tag := range tags {
+		filter.ids[i] = tag.ID
+	}
+	return filter, nil
+}
+
+/*
+Scope keeps the rows of a query whose record is tagged, a nil filter keeps them all
+- linksTable string, "tag_links" in the cards database, AttachedTagLinks in the transactions one
+- keyColumn string, the record key in the query, "uuid" or joinedCardItemKeySQL
+*/
+func (f *TagFilter) Scope(query *gorm.DB, linksTable string, source string, keyColumn string) *gorm.DB {
+	if f == nil {
+		return query
+	}
+	return query.Where(keyColumn+" IN (SELECT record_key FROM "+linksTable+" WHERE source = ? AND tag_id IN ?)", source, f.ids)
+}
+
+// ScopeCardItems keeps the rows of a cards query over holder_expenses (aliased e) whose line item is tagged
+func (f *TagFilter) ScopeCardItems(query *gorm.DB) *gorm.DB {
+	return f.Scope(query, "tag_links", RecordSourceCard, joinedCardItemKeySQL)
+}
+
+/*
+WithCardsDatabase runs fn on a connection of the transactions database where the cards database
+is attached as "cards", so the sheet records can be joined with their tag links (AttachedTagLinks)
+*/
+func WithCardsDatabase(transactionsDB *gorm.DB, cardsDB *gorm.DB, fn func(tx *gorm.DB) error) error {
+
+	var path string
+	if err := cardsDB.Raw("SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&path).Error; err != nil {
+		return fmt.Errorf("error reading the cards database file at WithCardsDatabase(): %w", err)
+	}
+	if path == "" {
+		return errors.New("the cards database has no file to attach")
+	}
+
+	return transactionsDB.Connection(func(tx *gorm.DB) error {
+		if err := tx.Exec("ATTACH DATABASE ? AS cards", path).Error; err != nil {
+			return fmt.Errorf("error attaching the cards database at WithCardsDatabase(): %w", err)
+		}
+		// A fresh session, the errors of fn stay in tx and would skip the statement
+		defer tx.Session(&gorm.Session{NewDB: true}).Exec("DETACH DATABASE cards")
+		return fn(tx.Session(&gorm.Session{NewDB: true}))
+	})
+}
+
+// LoadTaggedCardItems returns the card line items that pass the filter, of the statements between two dates (inclusive)
+func LoadTaggedCardItems(db *gorm.DB, filter *TagFilter, from time.Time, to time.Time) ([]HolderItem, error) {
+
+	var items []HolderItem
+	query := db.Table("holder_expenses AS e").
+		Select("e.document_number, r.card_type, r.resume_date, e.holder, e.position, e.date, e.description, e.amount").
+		Joins("JOIN resumes r ON e.document_number = r.document_number").
+		Where("strftime('%Y-%m-%d', r.resume_date) BETWEEN ? AND ?", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err := filter.ScopeCardItems(query).
+		Order("r.resume_date ASC, e.date ASC").
+		Scan(&items).Error; err != nil {
+		return nil, fmt.Errorf("error fetching tagged card expenses at LoadTaggedCardItems(): %w", err)
+	}
+	return items, nil
+}
+
+// TagTotal sums the tagged records of a period
+type TagTotal struct {
+	Tag      string  `json:"tag"`
+	Expenses float64 `json:"expenses"`
+	Incomes  float64 `json:"incomes"`
+	Cards    float64 `json:"cards"` // card line items, by statement date
+	Records  int     `json:"records"`
+}
+
+// taggedAmount is the amount of a tagged record of the period, one per tag and record
+type taggedAmount struct {
+	TagID    uint
+	Source   string
+	DateTime string // incomes only, stored in several layouts so the range is applied after parsing it
+	Amount   float64
+}
+
+/*
+TagTotals sums, per tag, the tagged expenses, incomes and card line items between two dates (inclusive).
+With a filter only its tags are summed. Tags without records in the period are left out.
+*/
+func TagTotals(transactionsDB *gorm.DB, cardsDB *gorm.DB, from time.Time, to time.Time, filter *TagFilter) ([]TagTotal, error) {
+
+	var rows []taggedAmount
+	fromStr, toStr := from.Format("2006-01-02"), to.Format("2006-01-02")
+	tagIDs := func(query *gorm.DB) *gorm.DB {
+		if filter == nil {
+			return query
+		}
+		return query.Where("l.tag_id IN ?", filter.ids)
+	}
+
+	err := WithCardsDatabase(transactionsDB, cardsDB, func(tx *gorm.DB) error {
+		if err := tagIDs(tx.Table("expenses AS e").
+			Select("l.tag_id, l.source, e.amount").
+			Joins("JOIN "+AttachedTagLinks+" l ON l.source = ? AND l.record_key = e.uuid", RecordSourceExpense).
+			Where("strftime('%Y-%m-%d', e.date) BETWEEN ? AND ?", fromStr, toStr)).
+			Scan(&rows).Error; err != nil {
+			return fmt.Errorf("error fetching tagged expenses: %w", err)
+		}
+
+		var incomes []taggedAmount
+		if err := tagIDs(tx.Table("incomes AS i").
+			Select("l.tag_id, l.source, i.date_time, i.amount").
+			Joins("JOIN "+AttachedTagLinks+" l ON l.source = ? AND l.record_key = i.uuid", RecordSourceIncome)).
+			Scan(&incomes).Error; err != nil {
+			return fmt.Errorf("error fetching tagged incomes: %w", err)
+		}
+		for _, income := range incomes {
+			date, err := utils.ParseTransactionDate(income.DateTime)
+			if err != nil || date.Before(from) || !date.Before(to.AddDate(0, 0, 1)) {
+				continue
+			}
+			rows = append(rows, income)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error at TagTotals(): %w", err)
+	}
+
+	var cards []taggedAmount
+	if err := tagIDs(cardsDB.Table("holder_expenses AS e").
+		Select("l.tag_id, l.source, e.amount").
+		Joins("JOIN resumes r ON e.document_number = r.document_number").
+		Joins("JOIN tag_links l ON l.source = ? AND l.record_key = "+joinedCardItemKeySQL, RecordSourceCard).
+		Where("strftime('%Y-%m-%d', r.resume_date) BETWEEN ? AND ?", fromStr, toStr)).
+		Scan(&cards).Error; err != nil {
+		return nil, fmt.Errorf("error fetching tagged card expenses at TagTotals(): %w", err)
+	}
+	rows = append(rows, cards...)
+
+	totals := []TagTotal{}
+	if len(rows) == 0 {
+		return totals, nil
+	}
+
+	byTag := make(map[uint]*TagTotal)
+	for _, row := range rows {
+		total := byTag[row.TagID]
+		if total == nil {
+			total = &TagTotal{}
+			byTag[row.TagID] = total
+		}
+		switch row.Source {
+		case RecordSourceExpense:
+			total.Expenses += row.Amount
+		case RecordSourceIncome:
+			total.Incomes += row.Amount
+		case RecordSourceCard:
+			total.Cards += row.Amount
+		}
+		total.Records++
+	}
+
+	ids := make([]uint, 0, len(byTag))
+	for id := range byTag {
+		ids = append(ids, id)
+	}
+	var tags []models.Tag
+	if err := cardsDB.Where("id IN ?", ids).Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("error fetching tags at TagTotals(): %w", err)
+	}
+	for _, tag := range tags {
+		total := byTag[tag.ID]
+		total.Tag = tag.Name
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Tag < totals[j].Tag })
+	return totals, nil
+}
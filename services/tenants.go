@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"finance-backend/config"
+	"finance-backend/models"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTenantSlug is the household that existed before tenants, it keeps the .env databases and settings
+const DefaultTenantSlug = "default"
+
+var ErrTenantNoSpreadsheet = errors.New("the household has no spreadsheet configured")
+
+var tenantSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,31}$`)
+
+// Tables of each household, the original ones are only created for new households since the default one already has them on disk
+var (
+	originalTransactionTables = []interface{}{&models.Incomes{}}
+	originalCardTables        = []interface{}{&models.Holder{}}
+	transactionTables         = []interface{}{&models.CPIIndex{}, &models.Expenses{}}
+	cardTables                = []interface{}{&models.Resume{}, &models.HolderExpense{}, &models.Anomaly{}, &models.InstallmentPlan{}, &models.Subscription{}, &models.SubscriptionPrice{}, &models.MerchantMapping{}, &models.CardPayment{}, &models.SplitRule{}, &models.SplitShare{}, &models.ExpenseSplit{}, &models.ExpenseSplitShare{}, &models.Tag{}, &models.TagLink{}, &models.Attachment{}}
+)
+
+func IsDefaultTenant(tenant models.Tenant) bool {
+	return tenant.Slug == DefaultTenantSlug
+}
+
+/*
+TenantValue returns a setting of the household, the default household falls back
+to the env variable when it is empty so existing .env files keep working
+*/
+func TenantValue(tenant models.Tenant, value string, env string) string {
+	if value == "" && IsDefaultTenant(tenant) {
+		return config.String(env)
+	}
+	return value
+}
+
+// TenantSheet returns the spreadsheet and sheet synced for the household (GS_SPREADSHEET_ID / GS_SHEET_ID for the default one)
+func TenantSheet(tenant models.Tenant) (string, string, error) {
+	spreadsheetID := TenantValue(tenant, tenant.SpreadsheetID, "GS_SPREADSHEET_ID")
+	if spreadsheetID == "" {
+		return "", "", ErrTenantNoSpreadsheet
+	}
+	return spreadsheetID, TenantValue(tenant, tenant.SheetName, "GS_SHEET_ID"), nil
+}
+
+// TenantPath partitions a storage folder: root for the default household, root/tenants/<slug> for the others
+func TenantPath(tenant models.Tenant, root string) string {
+	if IsDefaultTenant(tenant) {
+		return root
+	}
+	return filepath.Join(root, "tenants", tenant.Slug)
+}
+
+/*
+TenantDatabase returns an open database of the household
+- database string, TRANSACTION_DB | CARDS_DB, the env name of the alias (see GetDatabaseInstance)
+*/
+func TenantDatabase(tenant models.Tenant, database string) (*gorm.DB, error) {
+	if tenant.ID == 0 {
+		return nil, errors.New("no household selected")
+	}
+	db, ok := config.GetDB(tenantAlias(tenant, database))
+	if !ok {
+		return nil, fmt.Errorf("database not available")
+	}
+	return db, nil
+}
+
+// SystemDatabase returns the database with the users, API tokens and households, the CARDS_DB of the default household
+func SystemDatabase() (*gorm.DB, error) {
+	db, ok := config.GetDB(config.String("CARDS_DB"))
+	if !ok {
+		return nil, fmt.Errorf("database not available")
+	}
+	return db, nil
+}
+
+/*
+OpenTenantDatabases connects to the SQLite files of a household that is not the default one,
+TENANTS_PATH/<slug>/transactions.db and cards.db (TENANTS_PATH is "tenants" by default), and creates their tables
+*/
+func OpenTenantDatabases(tenant models.Tenant) (*gorm.DB, *gorm.DB, error) {
+
+	directory := filepath.Join(config.String("TENANTS_PATH"), tenant.Slug)
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("error creating household directory at OpenTenantDatabases(): %w", err)
+	}
+
+	transactionsDB, err := config.ConnectDB(tenantAlias(tenant, "TRANSACTION_DB"), filepath.Join(directory, "transactions.db"))
+	if err != nil {
+		return nil, nil, err
+	}
+	cardsDB, err := config.ConnectDB(tenantAlias(tenant, "CARDS_DB"), filepath.Join(directory, "cards.db"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := MigrateTenantDatabases(transactionsDB, cardsDB, true); err != nil {
+		return nil, nil, err
+	}
+	return transactionsDB, cardsDB, nil
+}
+
+/*
+MigrateTenantDatabases creates or updates the tables of a household
+- fresh bool, also creates the original tables (incomes, holders), only for new households
+*/
+func MigrateTenantDatabases(transactionsDB *gorm.DB, cardsDB *gorm.DB, fresh bool) error {
+	transactions, cards := transactionTables, cardTables
+	if fresh {
+		transactions = append(append([]interface{}{}, originalTransactionTables...), transactions...)
+		cards = append(append([]interface{}{}, originalCardTables...), cards...)
+	}
+	if err := transactionsDB.AutoMigrate(transactions...); err != nil {
+		return fmt.Errorf("error migrating transactions tables at MigrateTenantDatabases(): %w", err)
+	}
+	if err := cardsDB.AutoMigrate(cards...); err != nil {
+		return fmt.Errorf("error migrating cards tables at MigrateTenantDatabases(): %w", err)
+	}
+	return nil
+}
+
+/*
+SeedDefaultTenant creates the default household the first time and assigns to it
+the users created before households existed. Returns the default household.
+*/
+func SeedDefaultTenant(db *gorm.DB) (models.Tenant, error) {
+
+	var tenant models.Tenant
+	if err := db.Where("slug = ?", DefaultTenantSlug).Limit(1).Find(&tenant).Error; err != nil {
+		return tenant, fmt.Errorf("error fetching default household at SeedDefaultTenant(): %w", err)
+	}
+	if tenant.ID == 0 {
+		tenant = models.Tenant{Slug: DefaultTenantSlug, Name: "Default"}
+		if err := db.Create(&tenant).Error; err != nil {
+			return tenant, fmt.Errorf("error creating default household at SeedDefaultTenant(): %w", err)
+		}
+	}
+
+	if err := db.Model(&models.User{}).Where("tenant_id = 0 OR tenant_id IS NULL").Update("tenant_id", tenant.ID).Error; err != nil {
+		return tenant, fmt.Errorf("error assigning users at SeedDefaultTenant(): %w", err)
+	}
+	return tenant, nil
+}
+
+// ValidateTenant normalizes a household before it is stored, the statement paths must follow the CARD_STATEMENT_PATHS format
+func ValidateTenant(tenant *models.Tenant) error {
+	tenant.Slug = strings.ToLower(strings.TrimSpace(tenant.Slug))
+	tenant.Name = strings.TrimSpace(tenant.Name)
+	tenant.SpreadsheetID = strings.TrimSpace(tenant.SpreadsheetID)
+	tenant.SheetName = strings.TrimSpace(tenant.SheetName)
+	tenant.StatementPaths = strings.TrimSpace(tenant.StatementPaths)
+
+	if !tenantSlug.MatchString(tenant.Slug) {
+		return errors.New("invalid slug, expected 2 to 32 lowercase letters, digits or '-'")
+	}
+	if tenant.Name == "" {
+		return errors.New("name is required")
+	}
+	for _, entry := range strings.Split(tenant.StatementPaths, ",") {
+		if strings.TrimSpace(entry) != "" && len(strings.SplitN(entry, ":", 3)) != 3 {
+			return fmt.Errorf("invalid statement_paths entry %q, expected issuer:card_type:path", entry)
+		}
+	}
+	return nil
+}
+
+// tenantAlias names the connection of a household database in config.DBs
+func tenantAlias(tenant models.Tenant, database string) string {
+	if IsDefaultTenant(tenant) {
+		return config.String(database)
+	}
+	return tenant.Slug + "/" + config.String(database)
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+ParseTransactionDate parses the date_time column of expenses/incomes.
+The sheets have stored it in several layouts over time ("2006-01-02T15:04:05",
+"2006-01-02 15:04:05" and the Spanish "2/1/2006 15:04:05"), so every known
+layout is tried in order.
+*/
+func ParseTransactionDate(dateStr string) (time.Time, error) {
+	layouts := []string{
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2/1/2006 15:04:05",
+		"2006-01-02",
+		"2/1/2006",
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, dateStr); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown date format: %s", dateStr)
+}
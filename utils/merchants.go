@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	installmentRegex = regexp.MustCompile(`(?i)\bC\.\s*(\d{1,2})\s*/\s*(\d{1,2})`)
+	merchantNoise    = regexp.MustCompile(`[^a-z ]+`)
+)
+
+/*
+NormalizeMerchant reduces a card line item description to a comparable merchant name:
+lowercase, without installment marker, currency, digits or punctuation.
+"MERPAGO*KIOSCO 123 C.02/06" -> "merpago kiosco"
+*/
+func NormalizeMerchant(description string) string {
+	merchant := installmentRegex.ReplaceAllString(description, " ")
+	merchant = strings.ToLower(merchant)
+	merchant = merchantNoise.ReplaceAllString(merchant, " ")
+
+	var words []string
+	for _, word := range strings.Fields(merchant) {
+		if word != "usd" {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+/*
+ParseInstallment extracts the "C.NN/NN" installment marker of a card line item description
+- returns the installment number, the total installments and whether the marker was found
+*/
+func ParseInstallment(description string) (int, int, bool) {
+	match := installmentRegex.FindStringSubmatch(description)
+	if match == nil {
+		return 0, 0, false
+	}
+	number, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	total, err := strconv.Atoi(match[2])
+	if err != nil || total == 0 || number > total {
+		return 0, 0, false
+	}
+	return number, total, true
+}
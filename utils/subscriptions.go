@@ -1,18 +1,17 @@
 package utils
 
 import (
-	"finance-backend/config"
 	"strings"
 )
 
-func LoadMap(enviroment string) map[string]string {
-	env := config.GetEnv(enviroment)
-	if env == "" {
+// ParseMap reads "keyword:label" pairs separated by commas, keywords are lowercased
+func ParseMap(value string) map[string]string {
+	if value == "" {
 		return map[string]string{}
 	}
 
 	result := make(map[string]string)
-	pairs := strings.Split(env, ",")
+	pairs := strings.Split(value, ",")
 	for _, pair := range pairs {
 		parts := strings.SplitN(pair, ":", 2)
 		if len(parts) == 2 {
@@ -23,14 +22,15 @@ func LoadMap(enviroment string) map[string]string {
 	}
 	return result
 }
-func LoadLogosMap(enviroment string) map[string]string {
-	env := config.GetEnv(enviroment)
-	if env == "" {
+
+// ParseLogosMap reads "label:logo" pairs separated by commas, labels keep their case
+func ParseLogosMap(value string) map[string]string {
+	if value == "" {
 		return map[string]string{}
 	}
 
 	result := make(map[string]string)
-	pairs := strings.Split(env, ",")
+	pairs := strings.Split(value, ",")
 	for _, pair := range pairs {
 		parts := strings.SplitN(pair, ":", 2)
 		if len(parts) == 2 {